@@ -15,6 +15,14 @@ type backOffTracker struct {
 	jitter  funit.Measure
 	current time.Duration
 	end     time.Time
+	log     *logger
+}
+
+func (t *backOffTracker) logger() *logger {
+	if t.log == nil {
+		return &logger{id: "backoff"}
+	}
+	return t.log
 }
 
 func (t *backOffTracker) update(status error) {
@@ -25,27 +33,20 @@ func (t *backOffTracker) update(status error) {
 			if t.current == 0 {
 				t.current = t.initial
 			}
-			d := t.current
-			if t.jitter > 0 {
-				jtr := rand.Int63n(int64(funit.Measure(d) * t.jitter))
-				d += time.Duration(jtr)
-			}
-			if t.max > 0 && d > t.max {
-				d = t.max
-			}
+			d := jittered(t.current, t.max, t.jitter)
 			t.end = now.Add(d)
 			t.current = t.current << 1
 			if t.max > 0 && t.current > t.max {
 				t.current = t.max
 			}
-			logTrace(1, "backoff", "backing off for %v until %v", d, t.end)
+			t.logger().trace(1, "Backing off for %v until %v", d, t.end)
 		}
 	} else {
 		if now := time.Now(); now.After(t.end) {
 			// Ignore any success before end time as it may be coming
 			// from a concurrent attempt.
 			t.current = t.initial
-			logTrace(1, "backoff", "resetting to &v", t.current)
+			t.logger().trace(1, "Resetting to %v.", t.current)
 		}
 	}
 }
@@ -53,3 +54,32 @@ func (t *backOffTracker) update(status error) {
 func (t *backOffTracker) blackoutEnd() time.Time {
 	return t.end
 }
+
+// jittered returns d increased by a random amount of up to jitter fraction
+// of d. If max is positive, the result is capped at max.
+func jittered(d time.Duration, max time.Duration, jitter funit.Measure) time.Duration {
+	if jitter > 0 {
+		if j := int64(funit.Measure(d) * jitter); j > 0 {
+			d += time.Duration(rand.Int63n(j))
+		}
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+// exponentialBackOff returns back-off time for the n-th consecutive failure,
+// n starting at 1. The time doubles with every failure, starting with initial,
+// and is capped at max, if max is positive.
+func exponentialBackOff(n uint32, initial time.Duration, max time.Duration, jitter funit.Measure) time.Duration {
+	d := initial
+	for i := uint32(1); i < n && (max <= 0 || d < max); i++ {
+		if d<<1 <= d {
+			// overflow
+			break
+		}
+		d = d << 1
+	}
+	return jittered(d, max, jitter)
+}
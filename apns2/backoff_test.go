@@ -126,3 +126,18 @@ func TestNoJitterCappedBackOffTracker(t *testing.T) {
 	d = time.Millisecond
 	assert.InDelta(t, time.Now().Add(d).UnixNano(), s.blackoutEnd().UnixNano(), backOffTesterTimeDelta)
 }
+
+func TestExponentialBackOff(t *testing.T) {
+	d := time.Millisecond
+	assert.Equal(t, d, exponentialBackOff(0, d, 0, 0))
+	assert.Equal(t, d, exponentialBackOff(1, d, 0, 0))
+	assert.Equal(t, 2*d, exponentialBackOff(2, d, 0, 0))
+	assert.Equal(t, 8*d, exponentialBackOff(4, d, 0, 0))
+	assert.Equal(t, 5*d, exponentialBackOff(4, d, 5*d, 0))
+	assert.Equal(t, 5*d, exponentialBackOff(1000, d, 5*d, 0))
+	assert.True(t, exponentialBackOff(1000, d, 0, 0) > 0)
+	for i := 0; i < 100; i++ {
+		v := exponentialBackOff(2, d, 0, 10*funit.Percent)
+		assert.True(t, v >= 2*d && v < 2*d+2*d/10)
+	}
+}
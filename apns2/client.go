@@ -32,6 +32,8 @@ var (
 	ErrClientAlreadyClosed  = errors.New("apns2: client processing pipeline already closed")
 	ErrPushInterrupted      = errors.New("apns2: push request interrupted")
 	ErrCanceled             = errors.New("apns2: push request canceled")
+	ErrRoundTripAborted     = errors.New("apns2: push request aborted due to connection teardown")
+	ErrNoConnections        = errors.New("apns2: no connections to APN service available")
 )
 
 // NoSigner can be used where a RequestSigner is required when a push request
@@ -108,6 +110,14 @@ type Client struct {
 	// requests execution result is silently dropped.
 	Callback chan<- *Result
 
+	// Logger, if not nil, receives client's log entries. Package-wide Log
+	// and LogLevel are used if Logger is nil.
+	Logger StructuredLogger
+
+	// Observer, if not nil, is notified of processing pipeline lifecycle
+	// events. See Observer type declaration for delivery guarantees.
+	Observer Observer
+
 	retry chan *Request
 
 	out chan *Request
@@ -118,6 +128,7 @@ type Client struct {
 	wg    sync.WaitGroup
 	ctl   chan struct{} // our control channel
 	cctl  chan struct{} // submitter control channel
+	sctl  chan struct{} // submitter stop-accepting signal
 	gctl  chan struct{} // governor control channel
 	cdone chan struct{} // pipeline done processing signal
 
@@ -125,6 +136,32 @@ type Client struct {
 	waitCtr syncx.TickTockCounter
 	// counter of processed requests
 	rateCtr syncx.Counter
+
+	// cumulative metrics
+	stats statsCollector
+
+	// accounting of requests in processing pipeline
+	shutdown shutdownTracker
+
+	// paused state of processing pipeline
+	pause flagGate
+
+	// set while pushes fail fast due to prolonged outage
+	outage flagGate
+
+	// closed once MinConns streamers are running for the first time
+	ready chan struct{}
+	// receives the first streamer launch error encountered before that
+	launchErr chan error
+
+	// canceled on hard stop to abandon all outstanding HTTP/2 requests
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+
+	log *logger
+
+	// observer event delivery, nil if there's no observer
+	events *eventQueue
 }
 
 const (
@@ -148,77 +185,136 @@ func (c *Client) Start(wg *sync.WaitGroup) error {
 		return ErrClientAlreadyStarted
 	}
 	c.state = stateStarting
-	logInfo(c.Id, "Starting.")
+	c.log = newLogger(c.Logger, c.Id)
+	c.log.info("Starting.")
 	if wg != nil {
 		wg.Add(1)
 	}
 	c.wg.Add(1)
 	c.ctl = make(chan struct{})
 	c.cctl = make(chan struct{})
+	c.sctl = make(chan struct{})
+	c.shutdown.init()
+	c.pause.init()
+	c.outage.init()
+	if c.ready == nil {
+		c.ready = make(chan struct{})
+	}
+	c.launchErr = make(chan error, 1)
+	c.runCtx, c.cancelRun = context.WithCancel(context.Background())
 	c.gctl = make(chan struct{})
 	c.cdone = make(chan struct{})
 	c.out = make(chan *Request)
 	c.retry = make(chan *Request)
+	if c.Observer != nil {
+		c.events = newEventQueue(c.Observer)
+		go c.events.run(c.cdone)
+	}
 	c.gov = &governor{
-		id:      c.Id + "-Governor",
-		c:       c,
-		ctl:     c.gctl,
-		done:    c.cdone,
-		cfg:     c.ProcCfg,
-		minSust: c.ProcCfg.minSustainPollPeriods(),
+		id:   c.Id + "-Governor",
+		c:    c,
+		ctl:  c.gctl,
+		done: c.cdone,
+		stop: make(chan struct{}),
+		log:  c.log.with(c.Id+"-Governor", Field{FieldComponent, "governor"}),
+		cfg:  c.ProcCfg,
+		// slight buffering on the inbound channel to improve performance
+		retry:  make(chan *parkedRequest, 100),
+		reconf: make(chan *reconfRequest),
 	}
+	c.gov.live.Store(newLiveCfg(c.ProcCfg, c.CommsCfg, nil))
 	// TODO Figure out coordination of governor and retrier shutdowns.
 	go c.gov.run()
 	go c.runSubmitter(wg)
 	return nil
 }
 
-// Stop performs soft shutdown of the Client. All inflight requests are
-// given the chance to be executed.
-func (c *Client) Stop() error {
-	c.mu.Lock()
-	if c.state >= stateStopping {
-		c.mu.Unlock()
-		return ErrClientAlreadyClosed
+// StartContext starts Client processing pipeline as per Start and blocks
+// until MinConns streamers have established their connections to APN service.
+// If a streamer fails to launch before then, for example due to unreachable
+// gateway or a rejected TLS handshake, the launch error is returned.
+// If ctx is done first, ctx's error is returned. In either case the client
+// is shut down as per Kill.
+func (c *Client) StartContext(ctx context.Context) error {
+	if err := c.Start(nil); err != nil {
+		return err
 	}
-	c.state = stateStopping
-	logInfo(c.Id, "Stopping.")
-	close(c.cctl) // stop submitter
-	c.mu.Unlock()
-	c.wg.Wait()
-	close(c.out)
-	// Block until all processing is complete
-	// or we are signaled to terminate.
+	var ctxDone <-chan struct{}
+	if ctx != NoContext {
+		ctxDone = ctx.Done()
+	}
+	var err error
 	select {
-	case <-c.cdone:
-	case <-c.ctl:
+	case <-c.ready:
+		return nil
+	case err = <-c.launchErr:
+	case <-ctxDone:
+		err = ctx.Err()
 	}
-	if c.Callback != nil && c.Callback != NoCallback {
-		close(c.Callback)
+	c.log.warn("Failed to start: %v", err)
+	c.Kill()
+	return err
+}
+
+// Ready returns a channel that is closed once client's processing pipeline
+// has established MinConns connections to APN service for the first time.
+// The channel stays closed even if connections are lost later.
+func (c *Client) Ready() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ready == nil {
+		c.ready = make(chan struct{})
 	}
-	logInfo(c.Id, "Stopped.")
-	return nil
+	return c.ready
+}
+
+// Stop performs soft shutdown of the Client. All inflight requests are
+// given the chance to be executed. Stop is the same as StopContext
+// with no deadline.
+func (c *Client) Stop() error {
+	_, err := c.StopContext(NoContext)
+	return err
 }
 
 // Kill performs hard shutdown of the Client without waiting for the processing
-// pipeline to unwind. Inflight requests are discarded.
+// pipeline to unwind. Inflight requests are discarded without their callbacks
+// being invoked. Use KillAndCollect to get hold of the discarded requests.
 func (c *Client) Kill() error {
 	c.mu.Lock()
 	if c.state >= stateTerminating {
 		c.mu.Unlock()
 		return ErrClientAlreadyClosed
 	}
-	wasStopping := c.state == stateStopping
 	c.state = stateTerminating
-	logInfo(c.Id, "Terminating.")
-	if !wasStopping {
-		close(c.cctl)
+	c.killLocked()
+	c.mu.Unlock()
+	c.log.info("Terminated.")
+	return nil
+}
+
+// killLocked signals all pipeline components to terminate and cancels
+// all outstanding HTTP/2 requests. It is a no-op if hard stop has already
+// been initiated. Must be called with c.mu held.
+func (c *Client) killLocked() {
+	if c.isKilled() {
+		return
 	}
+	c.log.info("Terminating.")
+	ev := ClientEvent{Event: c.newEvent(), Hard: true}
+	c.notify(func(o Observer) { o.ClientStopping(ev) })
+	c.stopSubmitterLocked()
 	close(c.gctl)
+	c.cancelRun()
 	close(c.ctl) // unblock pending Stop() if there's one
-	c.mu.Unlock()
-	logInfo(c.Id, "Terminated.")
-	return nil
+}
+
+// stopSubmitterLocked signals submitter to stop. Must be called with c.mu held.
+func (c *Client) stopSubmitterLocked() {
+	select {
+	case <-c.cctl:
+	default:
+		close(c.cctl)
+	}
 }
 
 // Push asynchronously sends a Notification to the APN service.
@@ -232,6 +328,9 @@ func (c *Client) Kill() error {
 // signer was configured at the initialization time, the client's signer will
 // sign the request. NoSigner can be specified if the request must not be signed.
 //
+// If there have been no connections to APN service for longer than
+// ProcCfg.MaxOutage, ErrNoConnections is returned right away.
+//
 // This method will block if downstream capacity is exceeded. For non-blocking
 // behavior or to allow coordination with activity on other channels consider
 // creating a Request instance and writing it to client's Queue directly.
@@ -257,6 +356,53 @@ func (c *Client) Push(n *Notification, signer RequestSigner, ctx context.Context
 	return err
 }
 
+// PushSync sends a Notification to the APN service and blocks until the
+// outcome of the push is known. The returned error is the same as result's Err,
+// unless the push could not be initiated or ctx was canceled before
+// the result became available, in which case the result is nil.
+// If the client is shut down by hard stop before the outcome is known,
+// ErrPushInterrupted is returned with nil result.
+//
+// Context carries a deadline and a cancellation signal and allows you to
+// abandon the request while it is waiting in the queue, waiting for
+// an available HTTP/2 stream or while it is being sent to APN service.
+// Context can be nil or NoContext if no cancellation functionality is desired.
+//
+// Signer has the same semantics as in Push method.
+//
+// PushSync is safe for use in concurrent goroutines. Processing throughput
+// is only limited by client's ProcCfg, so large numbers of concurrent
+// PushSync calls are appropriate for achieving high push rates.
+func (c *Client) PushSync(ctx context.Context, n *Notification, signer RequestSigner) (*Result, error) {
+	// Buffering ensures the result can always be delivered without blocking
+	// even if we are no longer waiting for it.
+	cb := make(chan *Result, 1)
+	if err := c.Push(n, signer, ctx, cb); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	runCtx := c.runCtx
+	c.mu.RUnlock()
+	var done <-chan struct{}
+	if ctx != NoContext {
+		done = ctx.Done()
+	}
+	select {
+	case res := <-cb:
+		return res, res.Err
+	case <-done:
+		return nil, ErrCanceled
+	case <-runCtx.Done():
+		// Requests abandoned by hard stop are never called back.
+		select {
+		case res := <-cb:
+			return res, res.Err
+		default:
+		}
+		return nil, ErrPushInterrupted
+	}
+}
+
 // HasSigner returns `true` if there is a non-default signer configured
 // for signing push requests.
 func (c *Client) HasSigner() bool {
@@ -265,46 +411,96 @@ func (c *Client) HasSigner() bool {
 
 // TODO Separate submitter out
 func (c *Client) runSubmitter(wg *sync.WaitGroup) {
+	log := c.log.with(c.Id+"-Submitter", Field{FieldComponent, "submitter"})
 	done := false
 	c.mu.Lock()
-	if c.state != stateStarting {
-		done = true
-	} else {
+	switch {
+	case c.state == stateStarting:
 		c.state = stateRunning
+	case c.state >= stateTerminating:
+		done = true
 	}
+	// If the client is already stopping, Queue still needs draining.
 	c.mu.Unlock()
 	if !done {
-		logInfo(c.Id+"-Submitter", "Running.")
+		log.info("Running.")
 	}
+	queue, stopping := c.Queue, c.sctl
 	for !done {
+		retry, pending := c.retry, queue
+		paused, change := c.pause.get()
+		if paused {
+			retry, pending = nil, nil
+		}
 		select {
-		case req, _ := <-c.retry:
-			c.submit(req)
-		case req, ok := <-c.Queue:
+		case <-change:
+			// Re-evaluate paused state.
+		case req, _ := <-retry:
+			c.submitOrCancel(req)
+		case req, ok := <-pending:
 			if !ok {
-				// Queue is closed and we must do s soft shutdown.
-				// TODO Rework soft shutdown to account for retries.
-				done = true
+				// Queue is closed, but retries must still be serviced.
+				queue = nil
 				break
 			}
-			c.submit(req)
+			c.submitOrCancel(req)
+		case <-stopping:
+			// Submit whatever is left in the queue, but take no more.
+			for drained := false; !drained; {
+				select {
+				case req, ok := <-queue:
+					if !ok {
+						drained = true
+						break
+					}
+					c.submitOrCancel(req)
+				default:
+					drained = true
+				}
+			}
+			queue, stopping = nil, nil
+			c.shutdown.drain()
 		case <-c.cctl:
 			done = true
 		}
 	}
+	if stopping != nil {
+		// Hard stop; whatever is left in the queue is abandoned.
+		c.shutdown.drain()
+	}
 	c.mu.Lock()
 	c.state = stateClosed
 	c.mu.Unlock()
-	logInfo(c.Id+"-Submitter", "Stopped.")
+	log.info("Stopped.")
 	c.wg.Done()
 	if wg != nil {
 		wg.Done()
 	}
 }
 
+// submitOrCancel submits the request and reports back to the requester
+// if the request's context is canceled while waiting to be dispatched,
+// or if it fails fast due to prolonged outage.
+// Requests interrupted by hard stop are discarded.
+func (c *Client) submitOrCancel(req *Request) {
+	switch err := c.submit(req); err {
+	case ErrCanceled, ErrNoConnections:
+		c.callBack(req, nil, err, nil, c.cctl)
+	case ErrPushInterrupted:
+		c.discard(req, false)
+	}
+}
+
 func (c *Client) submit(req *Request) (rerr error) {
 	c.rateCtr.Add(1)
-	// TODO implement ctx timing out and cancellation checks
+	// Request must be accounted for before it is handed off.
+	if c.shutdown.dispatched(req) {
+		defer func() {
+			if rerr != nil {
+				c.shutdown.resolved(req)
+			}
+		}()
+	}
 	isBlocked := false
 	select {
 	case c.out <- req:
@@ -312,18 +508,82 @@ func (c *Client) submit(req *Request) (rerr error) {
 		isBlocked = true
 	}
 	if !isBlocked {
+		c.stats.addSubmitted()
 		return
 	}
+	var done <-chan struct{}
+	if req.Context != NoContext {
+		done = req.Context.Done()
+	}
 	c.waitCtr.Tick()
-	select {
-	case c.out <- req:
-	case <-c.cctl:
-		rerr = ErrPushInterrupted
+	for sent := false; !sent && rerr == nil; {
+		failing, change := c.outage.get()
+		if failing {
+			rerr = ErrNoConnections
+			break
+		}
+		select {
+		case c.out <- req:
+			c.stats.addSubmitted()
+			sent = true
+		case <-change:
+			// Re-evaluate outage state.
+		case <-c.cctl:
+			rerr = ErrPushInterrupted
+		case <-done:
+			rerr = ErrCanceled
+		}
 	}
 	c.waitCtr.Tock()
 	return
 }
 
+// callBack delivers the outcome of the request to request's callback channel,
+// or to client's Callback if the request does not specify one.
+// If delivery blocks, it is counted in waitCtr, if one is supplied, and it is
+// abandoned if ctl is signaled.
+func (c *Client) callBack(req *Request, resp *Response, err error, waitCtr *syncx.TickTockCounter, ctl <-chan struct{}) {
+	var topic string
+	if n := req.Notification; n != nil && n.Header != nil {
+		topic = n.Header.Topic
+	}
+	c.stats.addResult(topic, resp, err)
+	// Request leaves the pipeline only once its outcome is delivered.
+	defer c.shutdown.resolved(req)
+	if req.Callback == NoCallback {
+		return
+	}
+	tgt := c.Callback
+	if req.Callback != nil {
+		tgt = req.Callback
+	}
+	if tgt == nil || tgt == NoCallback {
+		return
+	}
+	res := &Result{
+		Notification: req.Notification,
+		Signer:       req.Signer,
+		Context:      req.Context,
+		Response:     resp,
+		Err:          err,
+		Attempts:     uint32(req.attemptCnt) + 1,
+		RetryDelay:   req.retryDelay,
+	}
+	select {
+	case tgt <- res:
+		return
+	default:
+	}
+	if waitCtr != nil {
+		waitCtr.Tick()
+		defer waitCtr.Tock()
+	}
+	select {
+	case tgt <- res:
+	case <-ctl:
+	}
+}
+
 func init() {
 	NoSigner = noSigner{}
 	NoCallback = make(chan *Result)
@@ -3,7 +3,10 @@
 package apns2
 
 import (
+	"context"
+	"crypto/tls"
 	"testing"
+	"time"
 
 	"github.com/baobabus/go-apns/cryptox"
 	"github.com/baobabus/go-apnsmock/apns2mock"
@@ -87,3 +90,148 @@ func TestClient_Signer_Good_1(t *testing.T) {
 		}
 	}
 }
+
+func TestClient_PushSync(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	err := c.Start(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	r, err := c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, r.IsAccepted())
+	assert.Equal(t, testNotif_Good, r.Notification)
+	r, err = c.PushSync(context.Background(), testNotif_BadDevice, DefaultSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 400, r.Response.StatusCode)
+	assert.Equal(t, ReasonBadDeviceToken, r.Response.RejectionReason)
+}
+
+func TestClient_PushSync_Canceled(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	err := c.Start(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, err := c.PushSync(ctx, testNotif_Good, DefaultSigner)
+	assert.Equal(t, ErrCanceled, err)
+	if r != nil {
+		assert.Equal(t, ErrCanceled, r.Err)
+	}
+}
+
+func TestClient_PushSync_Killed(t *testing.T) {
+	s := mustNewMockServerWithCfg(t, apns2mock.CommsCfg{
+		MaxConcurrentStreams: 500,
+		MaxConns:             1000,
+		ResponseTime:         time.Second,
+	})
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	// One request is in flight and the other one waits for a stream.
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+			errs <- err
+		}()
+	}
+	// Let the roundtrips get under way.
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, c.Kill())
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			assert.Equal(t, ErrPushInterrupted, err)
+		case <-time.After(time.Second):
+			t.Fatal("PushSync did not return after Kill")
+		}
+	}
+}
+
+func TestClient_NoConnections(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	// Every launch fails as the certificate cannot be parsed.
+	c.RootCA = &tls.Certificate{Certificate: [][]byte{[]byte("bogus")}}
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.MinDialBackOff = 20 * time.Millisecond
+	c.CommsCfg.MaxDialBackOff = 20 * time.Millisecond
+	c.ProcCfg.MaxOutage = 200 * time.Millisecond
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	start := time.Now()
+	err := c.Push(testNotif_Good, DefaultSigner, NoContext, nil)
+	assert.Equal(t, ErrNoConnections, err)
+	assert.True(t, time.Since(start) >= 200*time.Millisecond)
+	assert.Equal(t, ErrNoConnections, c.Push(testNotif_Good, DefaultSigner, NoContext, nil))
+	st := c.Stats()
+	assert.True(t, st.FailingFast)
+	assert.False(t, st.OutageSince.IsZero())
+	assert.Empty(t, st.Streamers)
+	// Launches keep being retried.
+	assert.True(t, st.DialFailures > 1)
+}
+
+func TestClient_StartContext(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	ready := c.Ready()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.StartContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	select {
+	case <-ready:
+	default:
+		t.Fatal("Should be ready")
+	}
+	assert.Equal(t, 1, len(c.Stats().Streamers))
+	_, err := c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrClientAlreadyStarted, c.StartContext(ctx))
+}
+
+func TestClient_StartContext_LaunchError(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	// Every launch fails as the certificate cannot be parsed.
+	c.RootCA = &tls.Certificate{Certificate: [][]byte{[]byte("bogus")}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := c.StartContext(ctx)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, context.DeadlineExceeded, err)
+	select {
+	case <-c.Ready():
+		t.Fatal("Should not be ready")
+	default:
+	}
+	assert.Equal(t, ErrClientNotRunning, c.Push(testNotif_Good, DefaultSigner, NoContext, nil))
+}
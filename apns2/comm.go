@@ -45,6 +45,30 @@ type CommsCfg struct {
 	// but a sinsibly long duration is acceptable.
 	KeepAlive time.Duration
 
+	// ReadIdleTimeout is the amount of time after which a health check
+	// using HTTP/2 PING frame is performed if no frame has been received
+	// on a connection. Such checks detect connections that were silently
+	// dropped by intermediate network devices. If zero, no health checks
+	// are performed.
+	ReadIdleTimeout time.Duration
+
+	// PingTimeout is the amount of time to wait for a response to a health
+	// check PING before the connection is deemed lost and is replaced.
+	// If zero, a default of 15 seconds is used.
+	PingTimeout time.Duration
+
+	// MaxConnectionAge, if positive, is the amount of time after which
+	// a connection is recycled. A replacement connection is established
+	// first, and the old connection is closed once all of its inflight
+	// requests have completed. This guards against connections that degrade
+	// over time.
+	MaxConnectionAge time.Duration
+
+	// MaxConnectionAgeJitter is used to calculate the random amount by which
+	// MaxConnectionAge of each connection is increased, so that connections
+	// established at the same time are not recycled all at once.
+	MaxConnectionAgeJitter funit.Measure
+
 	// MaxConcurrentStreams is the maximum allowed number of concurrent streams
 	// per HTTP/2 connection. If connection's MAX_CONCURRENT_STREAMS option
 	// is invoked by the remote side with a lower value, the remote request
@@ -61,6 +85,8 @@ var CommsFast = CommsCfg{
 	DialBackOffJitter:    10 * funit.Percent,
 	RequestTimeout:       30 * time.Second,
 	KeepAlive:            10 * time.Hour,
+	ReadIdleTimeout:      1 * time.Minute,
+	PingTimeout:          15 * time.Second,
 	MaxConcurrentStreams: 500,
 }
 
@@ -73,6 +99,8 @@ var CommsSlow = CommsCfg{
 	DialBackOffJitter:    10 * funit.Percent,
 	RequestTimeout:       60 * time.Second,
 	KeepAlive:            10 * time.Hour,
+	ReadIdleTimeout:      2 * time.Minute,
+	PingTimeout:          30 * time.Second,
 	MaxConcurrentStreams: 500,
 }
 
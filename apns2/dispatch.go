@@ -3,11 +3,17 @@
 package apns2
 
 import (
+	"container/heap"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/baobabus/go-apns/funit"
 	"github.com/baobabus/go-apns/scale"
+	"golang.org/x/net/http2"
 )
 
 // ProcCfg is a set of parameters that govern request processing flow
@@ -18,10 +24,31 @@ type ProcCfg struct {
 	// should be reattempted. This only applies to "retriable" failures.
 	MaxRetries uint32
 
+	// RetryPolicy, if not nil, is used to determine retry eligibility
+	// of failed push attempts, including the number of allowed retries.
+	// If neither RetryPolicy nor RetryEval is set, DefaultRetryPolicy is used.
+	RetryPolicy RetryPolicy
+
 	// RetryEval is the function that is called when a push attempt fails
-	// and retry eligibility needs to be determined.
+	// and retry eligibility needs to be determined. It is called for every
+	// attempt that is not accepted, including those that failed with
+	// a transport error, in which case the response may be nil.
+	// It is ignored if RetryPolicy is set.
 	RetryEval func(*Response, error) bool
 
+	// MinRetryBackOff is the amount of time by which the first retry
+	// of a failed push should be delayed. Delays double with each subsequent
+	// retry of the same push. If not set, a default of 1 second is used.
+	MinRetryBackOff time.Duration
+
+	// MaxRetryBackOff, if positive, is the maximum amount of time
+	// by which any retry should be delayed.
+	MaxRetryBackOff time.Duration
+
+	// RetryBackOffJitter is used to calculate the random amount to apply
+	// to each retry back-off time calculation.
+	RetryBackOffJitter funit.Measure
+
 	// MinConns is minimum number of concurrent connections to APN servers
 	// that should be kept open.
 	MinConns uint32
@@ -30,18 +57,44 @@ type ProcCfg struct {
 	// to APN service.
 	MaxConns uint32
 
+	// MaxOutage, if positive, is the amount of time without any usable
+	// connection to APN service after which pushes fail fast with
+	// ErrNoConnections instead of blocking. This applies to requests waiting
+	// to be dispatched, including retries. Pushes are dispatched as usual
+	// again as soon as a connection is established.
+	MaxOutage time.Duration
+
 	// MaxRate is the throughput cap specified in notifications per second.
 	// It is not strictly enforced as would be the case with a true rate
 	// limiter. Instead it only prevents additional scaling from taking place
 	// once the specified rate is reached.
+	// See StrictLimits for enabling strict enforcement.
 	MaxRate funit.Measure
 
 	// MaxBandwidth is the throughput cap specified in bits per second.
 	// It is not strictly enforced as would be the case with a true rate
 	// limiter. Instead it only prevents additional scaling from taking place
 	// once the specified rate is reached.
+	// See StrictLimits for enabling strict enforcement.
 	MaxBandwidth funit.Measure
 
+	// StrictLimits, if set to true, turns MaxRate and MaxBandwidth into
+	// strictly enforced caps. Pushes are then delayed by a token bucket rate
+	// limiter as needed. Any such delays are treated the same way as blocking
+	// on the outbound channel, so they do not cause additional scaling.
+	StrictLimits bool
+
+	// MaxRateBurst is the number of notifications that can be sent at once
+	// in excess of MaxRate after a period of lower activity.
+	// It is only used if StrictLimits is set. Values less than 1 are treated
+	// as 1.
+	MaxRateBurst uint32
+
+	// MaxBandwidthBurst is the amount of data, specified in bits, that can be
+	// sent at once in excess of MaxBandwidth after a period of lower activity.
+	// It is only used if StrictLimits is set.
+	MaxBandwidthBurst funit.Measure
+
 	// Scale specifies the manner of scaling up and winding down.
 	// Three scaling modes come prefefined: Incremental, Exponential and Constant.
 	// See below for more detail.
@@ -135,6 +188,32 @@ func (c *ProcCfg) minSustainPollPeriods() uint32 {
 	return uint32(res)
 }
 
+// isRetriable returns whether a push attempt that resulted in the supplied
+// response and error failed in a retriable manner, regardless of whether
+// any retries are allowed by MaxRetries.
+func (c *ProcCfg) isRetriable(resp *Response, err error) bool {
+	return c.retryLimitWith(resp, err, 1) > 0
+}
+
+// retryLimit returns the maximum number of times a push attempt that
+// resulted in the supplied response and error can be retried.
+func (c *ProcCfg) retryLimit(resp *Response, err error) uint32 {
+	return c.retryLimitWith(resp, err, c.MaxRetries)
+}
+
+func (c *ProcCfg) retryLimitWith(resp *Response, err error, maxRetries uint32) uint32 {
+	switch {
+	case c.RetryPolicy != nil:
+		return c.RetryPolicy.RetryLimit(resp, err, maxRetries)
+	case c.RetryEval != nil:
+		if c.RetryEval(resp, err) {
+			return maxRetries
+		}
+		return 0
+	}
+	return DefaultRetryPolicy.RetryLimit(resp, err, maxRetries)
+}
+
 // rateAsCount returns MaxRate expressed as number of counts per adjusted
 // MinSustain period. A rate of 1000/sec with MinSustain interval of 11 seconds
 // and PollInterval of 2 seconds is 12000 counts (6 poll intervals are needed
@@ -167,6 +246,9 @@ type governor struct {
 	ctl  <-chan struct{}
 	done chan<- struct{}
 
+	// closed on soft stop once dispatch channel is closed
+	stop chan struct{}
+
 	cfg ProcCfg
 
 	// minimun number of continuous sampling periods of performance
@@ -184,11 +266,33 @@ type governor struct {
 	maxCount uint64 // derived from cfg.MaxRate and minSust
 	maxSize  uint64 // derived from cfg.MaxBandwidth and minSust
 
-	retry chan *Request
+	retry chan *parkedRequest
+
+	// whether retry scheduler has been started
+	retrying bool
+
+	// guards sends on retry against retry scheduler stopping;
+	// retryStopped is set once the scheduler no longer reads from retry
+	retryMu      sync.RWMutex
+	retryStopped bool
+
+	log *logger
+
+	// configuration in effect, *liveCfg; cfg above is governor's own copy
+	// of its ProcCfg part
+	live atomic.Value
 
-	// active streamers and pending launchers
+	// new configurations submitted by Reconfigure
+	reconf chan *reconfRequest
+
+	// guards streamers, launchers, retirees and backOffTracker against
+	// concurrent stats collection; governor goroutine is the only writer
+	mu sync.Mutex
+
+	// active streamers, pending launchers and streamers being wound down
 	streamers map[*streamer]chan struct{}
 	launchers map[*launcher]chan struct{}
+	retirees  map[*streamer]chan struct{}
 	nextWId   uint
 
 	// "callback" channels streamers and launchers
@@ -196,12 +300,44 @@ type governor struct {
 	wExits chan *streamer
 	lExits chan *launcher
 
+	// streamers announce that their connections are going away
+	goAways chan *streamer
+
+	// streamers announce that their connections have reached maximum age
+	aged chan *streamer
+
+	// streamers due for recycling whose replacements are yet to be launched
+	dueRecycle map[*streamer]struct{}
+
+	// number of streamers that quit and are yet to be replaced
+	lost int
+
+	// whether client's readiness has been signaled
+	isReady bool
+
+	// start of the ongoing period with no active streamers, zero if there
+	// are some, and whether pushes are failing fast due to the outage
+	outageStart time.Time
+	failingFast bool
+
 	// time of last up- or down-scaling completion
 	lastScale time.Time
 
 	// tracker of blackout time due to back-off after failed connects
 	backOffTracker backOffTracker
 
+	// whether observer has been notified of back-off that hasn't yet ended
+	inBackOff bool
+
+	// cumulative counts of scaling events and failed launches
+	scaleUps     uint64
+	windDowns    uint64
+	dialFailures uint64
+	goAwayCnt    uint64
+
+	// number of requests awaiting retry
+	parked int64
+
 	isClosing bool
 }
 
@@ -220,9 +356,33 @@ func (c *waitCounter) acc(val uint32) {
 	}
 }
 
-// Must be called exactly once
-func (g *governor) run() {
-	logInfo(g.id, "Starting.")
+// liveCfg is a snapshot of client's configuration that is in effect.
+// It is never modified once published.
+type liveCfg struct {
+	proc  ProcCfg
+	comms CommsCfg
+	// strict rate limiter, nil if limits are not to be enforced
+	limiter *rateLimiter
+}
+
+// reconfRequest carries a new configuration to the governor.
+// Done is closed once the configuration is in effect.
+type reconfRequest struct {
+	cfg  *liveCfg
+	done chan struct{}
+}
+
+// config returns the configuration that is currently in effect.
+// It is safe to call config from any goroutine.
+func (g *governor) config() *liveCfg {
+	return g.live.Load().(*liveCfg)
+}
+
+// initCfg derives governor's scaling parameters from g.cfg.
+func (g *governor) initCfg() {
+	g.minSust = g.cfg.minSustainPollPeriods()
+	g.countAcc, g.maxCount = nil, 0
+	g.sizeAcc, g.maxSize = nil, 0
 	if g.cfg.MaxRate > 0 && g.minSust > 0 {
 		g.countAcc = newMovingAcc(int(g.minSust))
 		g.maxCount = g.cfg.rateAsCount()
@@ -231,57 +391,216 @@ func (g *governor) run() {
 		g.sizeAcc = newMovingAcc(int(g.minSust))
 		g.maxSize = g.cfg.bandwidthAsSize()
 	}
+}
+
+// initBackOff sets dial back-off parameters from the comms configuration.
+// Must be called with g.mu held.
+func (g *governor) initBackOff(comms *CommsCfg) {
+	g.backOffTracker.initial = 4 * time.Second
+	if comms.MinDialBackOff > 0 {
+		g.backOffTracker.initial = comms.MinDialBackOff
+	}
+	g.backOffTracker.max = comms.MaxDialBackOff
+	g.backOffTracker.jitter = comms.DialBackOffJitter
+}
+
+// startRetries starts retry scheduler if retries are allowed
+// and the scheduler is not yet running.
+func (g *governor) startRetries() {
+	if !g.retrying && (g.cfg.MaxRetries > 0 || g.cfg.RetryPolicy != nil) {
+		g.retrying = true
+		go g.runRetryScheduler()
+	}
+}
+
+// Must be called exactly once
+func (g *governor) run() {
+	g.log.info("Starting.")
+	g.initCfg()
 	g.wExits = make(chan *streamer)
 	g.lExits = make(chan *launcher)
+	g.goAways = make(chan *streamer)
+	g.aged = make(chan *streamer)
+	g.dueRecycle = make(map[*streamer]struct{})
+	g.mu.Lock()
 	g.streamers = make(map[*streamer]chan struct{})
 	g.launchers = make(map[*launcher]chan struct{})
-	g.backOffTracker.initial = 4 * time.Second
-	if g.c.CommsCfg.MinDialBackOff > 0 {
-		g.backOffTracker.initial = g.c.CommsCfg.MinDialBackOff
-	}
-	g.backOffTracker.max = g.c.CommsCfg.MaxDialBackOff
-	g.backOffTracker.jitter = g.c.CommsCfg.DialBackOffJitter
-	go g.runRetryForwarder()
+	g.retirees = make(map[*streamer]chan struct{})
+	g.initBackOff(&g.config().comms)
+	g.backOffTracker.log = g.log
+	g.mu.Unlock()
+	g.startRetries()
 	// Launch first MinConns streamers
 	g.tryScaleUp()
+	g.checkReady()
+	var tkr *time.Ticker
 	var tkrChan <-chan time.Time
-	if g.cfg.PollInterval > 0 {
-		tkr := time.NewTicker(g.cfg.PollInterval)
-		defer tkr.Stop()
-		tkrChan = tkr.C
+	resetTicker := func() {
+		if tkr != nil {
+			tkr.Stop()
+			tkr, tkrChan = nil, nil
+		}
+		if g.cfg.PollInterval > 0 {
+			tkr = time.NewTicker(g.cfg.PollInterval)
+			tkrChan = tkr.C
+		}
 	}
-	logInfo(g.id, "Running.")
+	resetTicker()
+	defer func() {
+		if tkr != nil {
+			tkr.Stop()
+		}
+	}()
+	g.log.info("Running.")
+	// Timers for restoring lost streamers once dial back-off is over
+	// and for failing pushes fast once outage has lasted long enough.
+	var restoreTmr, outageTmr <-chan time.Time
+	stop := g.stop
 	for done := false; !done; {
+		g.updateOutage()
+		if restoreTmr == nil && g.needsRestore() {
+			restoreTmr = time.After(g.backOffTracker.blackoutEnd().Sub(time.Now()))
+		}
+		if outageTmr == nil && g.cfg.MaxOutage > 0 && !g.outageStart.IsZero() && !g.failingFast {
+			outageTmr = time.After(g.outageStart.Add(g.cfg.MaxOutage).Sub(time.Now()))
+		}
 		select {
+		case <-stop:
+			// Soft stop: streamers exit once dispatch channel is drained,
+			// but there may be no streamers to begin with.
+			stop = nil
+			if !g.isClosing {
+				g.log.info("Stopping.")
+				g.isClosing = true
+			}
+		case <-restoreTmr:
+			restoreTmr = nil
+			g.restoreStreamers()
+		case <-outageTmr:
+			outageTmr = nil
+			if !g.outageStart.IsZero() && time.Since(g.outageStart) >= g.cfg.MaxOutage {
+				g.log.warn("No connections for %v, failing pushes.", time.Since(g.outageStart))
+				g.failingFast = true
+				g.c.outage.set(true)
+			}
 		case l := <-g.lExits:
 			// launcher finished
+			g.mu.Lock()
 			delete(g.launchers, l)
-			g.backOffTracker.update(l.err)
 			if w := l.worker; w != nil {
 				g.streamers[w] = w.ctl
 			} else if l.err != nil {
-				logWarn(g.id, "Error starting streamer: %v", l.err)
+				atomic.AddUint64(&g.dialFailures, 1)
+			}
+			g.mu.Unlock()
+			if r := l.replaces; r != nil {
+				if _, ok := g.streamers[r]; ok {
+					if l.worker != nil {
+						// Replacement is up, so the old streamer can go.
+						g.retireStreamer(r)
+					} else {
+						// Try again once back-off is over.
+						g.dueRecycle[r] = struct{}{}
+					}
+				}
+			}
+			ev := StreamerEvent{Event: g.c.newEvent(), StreamerId: l.id, Err: l.err}
+			if l.err != nil {
+				g.log.warn("Error starting streamer: %v", l.err)
+				g.c.notify(func(o Observer) { o.StreamerLaunchFailed(ev) })
+			} else {
+				g.c.notify(func(o Observer) { o.StreamerLaunched(ev) })
+			}
+			// Failed launches are retried once back-off is over
+			// as long as there are fewer than MinConns streamers.
+			g.updateBackOff(l.err)
+			if l.err != nil && !g.isReady {
+				select {
+				case g.c.launchErr <- l.err:
+				default:
+				}
 			}
+			g.checkReady()
 			if len(g.launchers) == 0 {
 				g.lastScale = time.Now()
 			}
-			// TODO Handle failed launches
 		case w := <-g.wExits:
 			// worker finished
+			delete(g.dueRecycle, w)
 			if w.inClosed && !g.isClosing {
 				// Soft stop: Client closed main channel. We are closing, too.
-				logInfo(g.id, "Stopping.")
+				g.log.info("Stopping.")
 				g.isClosing = true
 			}
+			if _, ok := g.retirees[w]; ok {
+				// Wound down streamer is not to be replaced.
+				g.mu.Lock()
+				delete(g.retirees, w)
+				g.mu.Unlock()
+				if len(g.retirees) == 0 {
+					g.lastScale = time.Now()
+				}
+				ev := StreamerEvent{Event: g.c.newEvent(), StreamerId: w.id}
+				g.c.notify(func(o Observer) { o.StreamerRetired(ev) })
+				break
+			}
+			g.mu.Lock()
 			delete(g.streamers, w)
+			g.mu.Unlock()
 			if w.didQuit {
-				// This needs to be on exponential back-off
+				ev := StreamerEvent{Event: g.c.newEvent(), StreamerId: w.id}
+				g.c.notify(func(o Observer) { o.StreamerQuit(ev) })
+				if time.Since(w.started) < g.backOffTracker.initial {
+					// Connection that is lost right away is not much better
+					// than one that could not be established.
+					g.updateBackOff(errEarlyQuit)
+				}
+				// Replacement is launched once back-off, if any, is over.
+				g.lost++
+			}
+		case w := <-g.goAways:
+			atomic.AddUint64(&g.goAwayCnt, 1)
+			ev := GoAwayEvent{Event: g.c.newEvent(), StreamerId: w.id, GoAway: w.goAwayErr}
+			g.c.notify(func(o Observer) { o.ConnectionGoingAway(ev) })
+			if _, ok := g.streamers[w]; !ok {
+				// Streamer is already being wound down.
+				break
+			}
+			// Streamer is let to drain as if it was wound down,
+			// and is replaced right away.
+			g.log.info("Replacing %s.", w.id)
+			g.mu.Lock()
+			delete(g.streamers, w)
+			g.retirees[w] = w.ctl
+			g.mu.Unlock()
+			if !g.isClosing {
 				g.launchStreamer()
 			}
+		case w := <-g.aged:
+			if _, ok := g.streamers[w]; ok && !g.isClosing {
+				g.recycleStreamer(w)
+			}
+		case r := <-g.reconf:
+			prevPoll := g.cfg.PollInterval
+			g.reconfigure(r.cfg)
+			if g.cfg.PollInterval != prevPoll {
+				resetTicker()
+			}
+			// The outage timer is re-armed with the new MaxOutage.
+			outageTmr = nil
+			close(r.done)
 		case <-tkrChan:
 			if g.isClosing {
 				break
 			}
+			if g.inBackOff && time.Now().After(g.backOffTracker.blackoutEnd()) {
+				g.endBackOff()
+			}
+			if g.c.pause.isSet() {
+				// Blocking while paused says nothing about the load.
+				g.discardCounters()
+				break
+			}
 			s := g.updateCountersAndEvalScaling()
 			if s > 0 {
 				g.tryScaleUp()
@@ -290,27 +609,71 @@ func (g *governor) run() {
 			}
 		case <-g.ctl:
 			// Hard stop command
-			logInfo(g.id, "Terminating.")
+			g.log.info("Terminating.")
 			done = true
 		}
 		if !done && g.isClosing {
-			done = len(g.streamers) == 0 && len(g.launchers) == 0
+			done = len(g.streamers) == 0 && len(g.launchers) == 0 && len(g.retirees) == 0
 		}
 	}
 	// signal launchers and streamers
-	logInfo(g.id, "Terminating launchers and streamers.")
+	g.log.info("Terminating launchers and streamers.")
 	for i, _ := range g.launchers {
 		close(i.ctl)
 	}
 	for i, _ := range g.streamers {
 		close(i.ctl)
 	}
+	for i, _ := range g.retirees {
+		close(i.ctl)
+	}
 	// TODO Signal forwarder to stop
-	logInfo(g.id, "Stopped.")
+	g.log.info("Stopped.")
 	// Signal parent
 	close(g.done)
 }
 
+// reconfigure puts a new configuration into effect. Limits and scaling
+// parameters apply right away, new streamers use new comms settings
+// and existing streamers adopt new stream concurrency cap. Surplus streamers
+// are wound down if MaxConns is lowered, while any shortfall due to raised
+// MinConns is made up for the same way as lost streamers are.
+func (g *governor) reconfigure(lc *liveCfg) {
+	g.log.info("Reconfiguring.")
+	g.cfg = lc.proc
+	g.initCfg()
+	g.discardCounters()
+	g.mu.Lock()
+	g.initBackOff(&lc.comms)
+	g.mu.Unlock()
+	// Retry scheduler must be running before streamers can see
+	// the configuration that allows retries.
+	g.startRetries()
+	g.live.Store(lc)
+	for w, _ := range g.streamers {
+		w.httpClient.setCfgCap(lc.comms.MaxConcurrentStreams)
+	}
+	for w, _ := range g.retirees {
+		w.httpClient.setCfgCap(lc.comms.MaxConcurrentStreams)
+	}
+	if n := len(g.streamers) - int(g.cfg.MaxConns); n > 0 && !g.isClosing {
+		g.windDown(n)
+	}
+}
+
+// discardCounters drops counts accumulated since the last tick and restarts
+// the evaluation of sustained blocking.
+func (g *governor) discardCounters() {
+	g.c.waitCtr.Fold()
+	g.c.rateCtr.Draw()
+	for s, _ := range g.streamers {
+		s.waitCtr.Fold()
+		s.sizeCtr.Draw()
+	}
+	g.inCtr = waitCounter{}
+	g.outCtr = waitCounter{}
+}
+
 func (g *governor) updateCountersAndEvalScaling() int {
 	shouldCount := g.cfg.MaxRate > 0 && g.minSust > 0
 	shouldSize := g.cfg.MaxBandwidth > 0 && g.minSust > 0
@@ -358,29 +721,174 @@ const (
 
 func (g *governor) tryScaleUp() {
 	delta := g.allowedScaleDelta(forScaleUp)
-	logTrace(2, g.id, "tryScaleUp delta = %d", delta)
+	g.log.trace(2, "tryScaleUp delta = %d", delta)
 	if delta <= 0 {
 		return
 	}
+	atomic.AddUint64(&g.scaleUps, 1)
+	prov := uint32(len(g.streamers) + len(g.launchers))
+	ev := ScaleEvent{Event: g.c.newEvent(), From: prov, To: prov + uint32(delta)}
+	g.c.notify(func(o Observer) { o.ScaleUp(ev) })
 	for i := 0; i < delta; i++ {
 		g.launchStreamer()
 	}
 }
 
 func (g *governor) tryWindDown() {
-	// TODO Implement winding down
+	delta := g.allowedScaleDelta(forWindDown)
+	g.log.trace(2, "tryWindDown delta = %d", delta)
+	if delta >= 0 {
+		return
+	}
+	g.windDown(-delta)
+}
+
+// windDown retires up to n least utilized streamers.
+func (g *governor) windDown(n int) {
+	// Least utilized streamers have the fewest roundtrips to drain.
+	ws := make([]*streamer, 0, len(g.streamers))
+	for w, _ := range g.streamers {
+		ws = append(ws, w)
+	}
+	sort.Sort(byUtilization(ws))
+	atomic.AddUint64(&g.windDowns, 1)
+	if n > len(ws) {
+		n = len(ws)
+	}
+	ev := ScaleEvent{Event: g.c.newEvent(), From: uint32(len(ws)), To: uint32(len(ws) - n)}
+	g.c.notify(func(o Observer) { o.WindDown(ev) })
+	for i := 0; i < n; i++ {
+		delete(g.dueRecycle, ws[i])
+		g.retireStreamer(ws[i])
+	}
+}
+
+// retireStreamer signals the streamer to stop taking new requests and to
+// exit once all of its pending roundtrips have completed.
+func (g *governor) retireStreamer(w *streamer) {
+	g.log.info("Winding down %s.", w.id)
+	g.mu.Lock()
+	delete(g.streamers, w)
+	g.retirees[w] = w.ctl
+	g.mu.Unlock()
+	close(w.retire)
+}
+
+// updateBackOff updates dial back-off with the outcome of a connection
+// attempt and notifies the observer if back-off has started or ended.
+func (g *governor) updateBackOff(err error) {
+	g.mu.Lock()
+	prevEnd := g.backOffTracker.blackoutEnd()
+	g.backOffTracker.update(err)
+	end := g.backOffTracker.blackoutEnd()
+	g.mu.Unlock()
+	if !end.Equal(prevEnd) {
+		g.inBackOff = true
+		ev := BackOffEvent{Event: g.c.newEvent(), Until: end}
+		g.c.notify(func(o Observer) { o.BackOffStarted(ev) })
+	} else if err == nil {
+		g.endBackOff()
+	}
+}
+
+// needsRestore returns true if streamers need to be launched to replace
+// the ones that were lost or that failed to launch.
+func (g *governor) needsRestore() bool {
+	if g.isClosing {
+		return false
+	}
+	return g.lost > 0 || len(g.dueRecycle) > 0 || len(g.streamers)+len(g.launchers) < int(g.cfg.MinConns)
+}
+
+// restoreStreamers launches replacements for streamers that quit or are
+// due for recycling, and tops the number of streamers up to MinConns.
+// Nothing is launched while dial back-off is in effect.
+func (g *governor) restoreStreamers() {
+	if !g.needsRestore() || g.backOffTracker.blackoutEnd().After(time.Now()) {
+		return
+	}
+	for w, _ := range g.dueRecycle {
+		delete(g.dueRecycle, w)
+		if _, ok := g.streamers[w]; ok {
+			g.recycleStreamer(w)
+		}
+	}
+	n := g.lost
+	if min := int(g.cfg.MinConns) - len(g.streamers) - len(g.launchers); n < min {
+		n = min
+	}
+	g.lost = 0
+	if n > 0 {
+		g.log.info("Launching %d streamers to restore connections.", n)
+	}
+	for ; n > 0; n-- {
+		g.launchStreamer()
+	}
+}
+
+// checkReady signals client's readiness once there are MinConns streamers.
+func (g *governor) checkReady() {
+	if !g.isReady && len(g.streamers) >= int(g.cfg.MinConns) {
+		g.isReady = true
+		g.log.info("Ready.")
+		close(g.c.ready)
+	}
+}
+
+// updateOutage keeps track of periods with no active streamers.
+func (g *governor) updateOutage() {
+	if len(g.streamers) > 0 || g.isClosing {
+		if !g.outageStart.IsZero() {
+			g.mu.Lock()
+			g.outageStart = time.Time{}
+			g.mu.Unlock()
+			if g.failingFast {
+				g.log.info("Connections restored.")
+				g.failingFast = false
+				g.c.outage.set(false)
+			}
+		}
+	} else if g.outageStart.IsZero() {
+		g.mu.Lock()
+		g.outageStart = time.Now()
+		g.mu.Unlock()
+	}
+}
+
+// endBackOff notifies the observer that dial back-off is no longer
+// in effect, if it was.
+func (g *governor) endBackOff() {
+	if !g.inBackOff {
+		return
+	}
+	g.inBackOff = false
+	ev := BackOffEvent{Event: g.c.newEvent(), Until: g.backOffTracker.blackoutEnd()}
+	g.c.notify(func(o Observer) { o.BackOffEnded(ev) })
 }
 
 func (g *governor) launchStreamer() {
+	g.launch(nil)
+}
+
+// recycleStreamer launches a replacement for w. Streamer w keeps taking
+// requests until the replacement is running, and is wound down then.
+func (g *governor) recycleStreamer(w *streamer) {
+	g.log.info("Recycling %s.", w.id)
+	g.launch(w)
+}
+
+func (g *governor) launch(replaces *streamer) {
 	wid := fmt.Sprintf(g.id+"-Streamer-%d", g.nextWId)
-	l := &launcher{gov: g, id: wid, done: g.lExits, ctl: make(chan struct{})}
+	l := &launcher{gov: g, id: wid, done: g.lExits, ctl: make(chan struct{}), replaces: replaces}
 	g.nextWId++
+	g.mu.Lock()
 	g.launchers[l] = l.ctl
+	g.mu.Unlock()
 	go l.launch()
 }
 
 func (g *governor) allowedScaleDelta(forScaleUp bool) int {
-	if g.isClosing || len(g.launchers) > 0 {
+	if g.isClosing || len(g.launchers) > 0 || len(g.retirees) > 0 {
 		return 0
 	}
 	now := time.Now()
@@ -419,20 +927,31 @@ type launcher struct {
 	ctl    chan struct{}
 	err    error
 	worker *streamer
+
+	// streamer to be wound down once the launched one is running
+	replaces *streamer
 }
 
+// errEarlyQuit is used to account for streamers that quit shortly after launch
+// as failed connection attempts.
+var errEarlyQuit = errors.New("streamer quit shortly after launch")
+
 func (l *launcher) launch() {
 	w := &streamer{
 		id:        l.id,
 		c:         l.gov.c,
 		gov:       l.gov,
+		log:       l.gov.c.log.with(l.id, Field{FieldStreamer, l.id}),
 		in:        l.gov.c.out,
-		out:       l.gov.c.Callback,
 		warmStart: true,
 		ctl:       make(chan struct{}),
+		retire:    make(chan struct{}),
+		quit:      make(chan struct{}, 1),
+		goAway:    make(chan *http2.GoAwayError, 1),
 		done:      l.gov.wExits,
 	}
 	if l.err = w.start(nil); l.err == nil {
+		w.started = time.Now()
 		l.worker = w
 	}
 	// read from ctl prevents blocking on done if the governor
@@ -443,13 +962,47 @@ func (l *launcher) launch() {
 	}
 }
 
-// TODO Rework forwarder and streamers so that inbound channel can be closed
+// retryBackOff returns the amount of time by which n-th retry of a push
+// should be delayed.
+func (c *ProcCfg) retryBackOff(n uint32) time.Duration {
+	initial := c.MinRetryBackOff
+	if initial <= 0 {
+		initial = time.Second
+	}
+	return exponentialBackOff(n, initial, c.MaxRetryBackOff, c.RetryBackOffJitter)
+}
+
+// parkedRequest is a request that is waiting to be retried along with
+// the outcome of its last attempt.
+type parkedRequest struct {
+	req   *Request
+	resp  *Response
+	err   error
+	due   time.Time
+	delay time.Duration
+}
+
+// retryQueue is a min-heap of parked requests ordered by their due time.
+type retryQueue []*parkedRequest
+
+func (q retryQueue) Len() int            { return len(q) }
+func (q retryQueue) Less(i, j int) bool  { return q[i].due.Before(q[j].due) }
+func (q retryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *retryQueue) Push(x interface{}) { *q = append(*q, x.(*parkedRequest)) }
+func (q *retryQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	res := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return res
+}
+
+// TODO Rework scheduler and streamers so that inbound channel can be closed
 // by the client to indicate end of input, while allowing any retry requests
 // to finish.
-func (g *governor) runRetryForwarder() {
-	if g.cfg.MaxRetries == 0 {
-		return
-	}
+func (g *governor) runRetryScheduler() {
+	log := g.c.log.with(g.id+"-RetryScheduler", Field{FieldComponent, "retry_scheduler"})
 	// Retry requests will be re-queued with the Client. We need to ensure
 	// that any blocking on the Client inbound channel is dealt with in a way
 	// that doesn't block our streamers.
@@ -459,44 +1012,138 @@ func (g *governor) runRetryForwarder() {
 	var buf chan *Request
 	bufSize := 500
 	cnt := 0
-	// slight buffering on the inbound channel to improve performance
-	g.retry = make(chan *Request, 100)
-	logInfo(g.id+"-RetryForwarder", "Running.")
+	forward := func(req *Request) {
+		if buf == nil || cnt >= bufSize {
+			if buf != nil {
+				// signal bufferedForwarder to return
+				close(buf)
+			}
+			buf = make(chan *Request, bufSize)
+			go bufferedForwarder(buf, g.c, g.ctl)
+			cnt = 0
+		}
+		buf <- req
+		cnt++
+	}
+	// Requests are parked until their retry back-off expires.
+	var q retryQueue
+	tmr := time.NewTimer(time.Hour)
+	tmr.Stop()
+	var tmrC <-chan time.Time
+	log.info("Running.")
 	for done := false; !done; {
 		select {
-		case req := <-g.retry:
-			if buf == nil || cnt >= bufSize {
-				if buf != nil {
-					// signal bufferedForwarder to return
-					close(buf)
+		case pr := <-g.retry:
+			g.park(&q, pr)
+			atomic.StoreInt64(&g.parked, int64(len(q)))
+		case <-tmrC:
+			tmrC = nil
+		case <-g.ctl:
+			// Hard stop; parked requests are abandoned, and so are
+			// requests still buffered in retry channel.
+			for _, pr := range q {
+				g.c.discard(pr.req, false)
+			}
+			q = nil
+			g.retryMu.Lock()
+			g.retryStopped = true
+			g.retryMu.Unlock()
+			for drained := false; !drained; {
+				select {
+				case pr := <-g.retry:
+					g.c.discard(pr.req, false)
+				default:
+					drained = true
 				}
-				buf = make(chan *Request, bufSize)
-				go bufferedForwarder(buf, g.c, g.ctl)
-				cnt = 0
 			}
-			buf <- req
-		case <-g.ctl:
 			done = true
+			continue
+		}
+		now := time.Now()
+		for len(q) > 0 && !q[0].due.After(now) {
+			pr := heap.Pop(&q).(*parkedRequest)
+			if ctx := pr.req.Context; ctx != NoContext && ctx.Err() != nil {
+				// Retry never took place.
+				pr.req.attemptCnt--
+				go g.c.callBack(pr.req, pr.resp, ErrCanceled, nil, g.ctl)
+				continue
+			}
+			pr.req.retryDelay = pr.delay
+			forward(pr.req)
+		}
+		atomic.StoreInt64(&g.parked, int64(len(q)))
+		if !tmr.Stop() {
+			select {
+			case <-tmr.C:
+			default:
+			}
+		}
+		tmrC = nil
+		if len(q) > 0 {
+			tmr.Reset(q[0].due.Sub(now))
+			tmrC = tmr.C
 		}
 	}
-	logInfo(g.id+"-RetryForwarder", "Stopped.")
+	if buf != nil {
+		// signal bufferedForwarder to return
+		close(buf)
+	}
+	log.info("Stopped.")
+}
+
+// park schedules retry of the request after a back-off delay. If the retry
+// cannot take place before request's context deadline or before
+// the notification expires, the retry is abandoned and the outcome
+// of the last attempt is reported back.
+func (g *governor) park(q *retryQueue, pr *parkedRequest) {
+	req := pr.req
+	pr.delay = g.config().proc.retryBackOff(uint32(req.attemptCnt))
+	pr.due = time.Now().Add(pr.delay)
+	abandon := false
+	if ctx := req.Context; ctx != NoContext {
+		if dl, ok := ctx.Deadline(); ok && dl.Before(pr.due) {
+			abandon = true
+		}
+	}
+	if h := req.Notification.Header; h != nil && !h.Expiration.IsZero() && h.Expiration.Before(pr.due) {
+		abandon = true
+	}
+	if abandon {
+		g.log.trace(1, "Abandoning retry of %v.", req)
+		// Retry never took place, so the outcome is that of the last attempt.
+		req.attemptCnt--
+		go g.c.callBack(req, pr.resp, pr.err, nil, g.ctl)
+		return
+	}
+	heap.Push(q, pr)
+}
+
+// bufferedForwarder forwards retries to the client until in is closed.
+// On hard stop all remaining retries are discarded.
+// requestRetry hands pr over to retry scheduler. It returns false if
+// the scheduler has been stopped by hard stop, in which case pr is not taken.
+func (g *governor) requestRetry(pr *parkedRequest) bool {
+	// Senders hold read lock, so once the scheduler has set retryStopped
+	// nothing more can end up in the channel.
+	g.retryMu.RLock()
+	defer g.retryMu.RUnlock()
+	if g.retryStopped {
+		return false
+	}
+	select {
+	case g.retry <- pr:
+		return true
+	case <-g.ctl:
+		return false
+	}
 }
 
 func bufferedForwarder(in <-chan *Request, client *Client, ctl <-chan struct{}) {
-	for done := false; !done; {
+	for req := range in {
 		select {
-		case req, ok := <-in:
-			if !ok {
-				done = true
-				break
-			}
-			select {
-			case client.retry <- req:
-			case <-ctl:
-				done = true
-			}
+		case client.retry <- req:
 		case <-ctl:
-			done = true
+			client.discard(req, false)
 		}
 	}
 }
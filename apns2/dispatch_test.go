@@ -3,8 +3,12 @@
 package apns2
 
 import (
+	"container/heap"
+	"context"
 	"testing"
+	"time"
 
+	"github.com/baobabus/go-apns/scale"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,3 +52,121 @@ func TestMovingAcc(t *testing.T) {
 	assert.Equal(t, 1, s.pos)
 	assert.Equal(t, uint64(10), v)
 }
+
+func newTestGovernor(cfg ProcCfg, nStreamers int) *governor {
+	g := &governor{
+		id:        "Governor",
+		c:         &Client{},
+		cfg:       cfg,
+		streamers: make(map[*streamer]chan struct{}),
+		launchers: make(map[*launcher]chan struct{}),
+		retirees:  make(map[*streamer]chan struct{}),
+	}
+	g.live.Store(newLiveCfg(cfg, CommsCfg{}, nil))
+	for i := 0; i < nStreamers; i++ {
+		w := &streamer{
+			ctl:        make(chan struct{}),
+			retire:     make(chan struct{}),
+			httpClient: &HTTPClient{cnt: uint32(i)},
+		}
+		g.streamers[w] = w.ctl
+	}
+	return g
+}
+
+func TestGovernorWindDown(t *testing.T) {
+	cfg := ProcCfg{MinConns: 2, MaxConns: 10, Scale: scale.Incremental(2)}
+	g := newTestGovernor(cfg, 5)
+	g.tryWindDown()
+	assert.Equal(t, 3, len(g.streamers))
+	assert.Equal(t, 2, len(g.retirees))
+	for w, _ := range g.retirees {
+		// Least utilized streamers must be wound down first
+		assert.True(t, w.inFlight() < 2)
+		select {
+		case <-w.retire:
+		default:
+			t.Fatal("Retiree should have been signaled")
+		}
+	}
+	// No further winding down while previous one is in progress
+	g.tryWindDown()
+	assert.Equal(t, 3, len(g.streamers))
+	// Never below MinConns
+	g.retirees = make(map[*streamer]chan struct{})
+	g.tryWindDown()
+	assert.Equal(t, 2, len(g.streamers))
+	assert.Equal(t, 1, len(g.retirees))
+	g.retirees = make(map[*streamer]chan struct{})
+	g.tryWindDown()
+	assert.Equal(t, 2, len(g.streamers))
+	assert.Equal(t, 0, len(g.retirees))
+}
+
+func TestGovernorWindDownSettlePeriod(t *testing.T) {
+	cfg := ProcCfg{MinConns: 1, MaxConns: 10, Scale: scale.Incremental(1), SettlePeriod: time.Hour}
+	g := newTestGovernor(cfg, 3)
+	g.lastScale = time.Now()
+	g.tryWindDown()
+	assert.Equal(t, 3, len(g.streamers))
+	assert.Equal(t, 0, len(g.retirees))
+}
+
+func TestRetryQueue(t *testing.T) {
+	now := time.Now()
+	var q retryQueue
+	for _, d := range []int{3, 1, 2} {
+		heap.Push(&q, &parkedRequest{due: now.Add(time.Duration(d) * time.Second)})
+	}
+	for _, d := range []int{1, 2, 3} {
+		pr := heap.Pop(&q).(*parkedRequest)
+		assert.Equal(t, now.Add(time.Duration(d)*time.Second), pr.due)
+	}
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestGovernorPark(t *testing.T) {
+	g := newTestGovernor(ProcCfg{MinRetryBackOff: time.Second, MaxRetryBackOff: 3 * time.Second}, 0)
+	g.c = &Client{}
+	var q retryQueue
+	cb := make(chan *Result, 10)
+	// Attempt count is incremented by the streamer before the request is parked.
+	newReq := func(ctx context.Context, h *Header, attempts int) *parkedRequest {
+		return &parkedRequest{req: &Request{
+			Notification: &Notification{Header: h},
+			Context:      ctx,
+			Callback:     cb,
+			attemptCnt:   attempts,
+		}}
+	}
+	g.park(&q, newReq(NoContext, &Header{}, 1))
+	assert.Equal(t, 1, q.Len())
+	assert.Equal(t, time.Second, q[0].delay)
+	// Delay is only reported once the retry takes place.
+	assert.Equal(t, time.Duration(0), q[0].req.retryDelay)
+	g.park(&q, newReq(NoContext, &Header{}, 3))
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, 3*time.Second, q[1].delay)
+	// Context deadline before due time
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	g.park(&q, newReq(ctx, &Header{}, 1))
+	assert.Equal(t, 2, q.Len())
+	// Expiration before due time
+	g.park(&q, newReq(NoContext, &Header{Expiration: time.Now().Add(500 * time.Millisecond)}, 2))
+	assert.Equal(t, 2, q.Len())
+	g.park(&q, newReq(NoContext, &Header{Expiration: time.Now().Add(time.Hour)}, 1))
+	assert.Equal(t, 3, q.Len())
+	// Abandoned retries report the attempts that were actually made.
+	attempts := map[uint32]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case res := <-cb:
+			attempts[res.Attempts]++
+			assert.Equal(t, time.Duration(0), res.RetryDelay)
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for result")
+		}
+	}
+	assert.Equal(t, map[uint32]int{1: 1, 2: 1}, attempts)
+}
@@ -22,6 +22,7 @@ import (
 var (
 	ErrHTTPClientClosed = errors.New("HTTPClient: attempt to close already closed client")
 	ErrNoConnectionPool = errors.New("HTTPClient: no connection pool")
+	ErrPingTimeout      = errors.New("HTTPClient: connection lost due to PING timeout")
 )
 
 // HTTPClient wraps http.Client and augments it with HTTP/2 stream
@@ -44,6 +45,7 @@ type HTTPClient struct {
 	mu       sync.Mutex
 	cond     *sync.Cond
 	connPool http2.ClientConnPool
+	conn     *http2.ClientConn // first connection obtained from connPool
 	actCap   uint32
 	effCap   uint32
 	cnt      uint32
@@ -52,7 +54,20 @@ type HTTPClient struct {
 	tkr *time.Ticker
 	ctl chan struct{}
 
+	// closed when a health check PING fails
+	lostPing     chan struct{}
+	lostPingOnce sync.Once
+
 	initOnce sync.Once
+
+	log *logger
+}
+
+func (c *HTTPClient) logger() *logger {
+	if c.log == nil {
+		return &logger{id: "HTTPClient"}
+	}
+	return c.log
 }
 
 // NewHTTPClient creates a new HTTPClient for handling HTTP requests
@@ -63,6 +78,8 @@ func NewHTTPClient(gateway string, commsCfg CommsCfg, cCert *tls.Certificate, ro
 	t := &http2.Transport{
 		DialTLS:            makeDialer(commsCfg),
 		DisableCompression: true, // As per Apple spec
+		ReadIdleTimeout:    commsCfg.ReadIdleTimeout,
+		PingTimeout:        commsCfg.PingTimeout,
 	}
 	tlsConfig := t.TLSClientConfig
 	if cCert != nil {
@@ -97,10 +114,27 @@ func NewHTTPClient(gateway string, commsCfg CommsCfg, cCert *tls.Certificate, ro
 		precise: false,
 		pollInt: 0,
 		cfgCap:  1,
+
+		lostPing: make(chan struct{}),
 	}
+	t.CountError = res.countError
 	return res, nil
 }
 
+// countError is called by HTTP/2 transport on transport errors.
+func (c *HTTPClient) countError(errType string) {
+	if errType == "conn_close_lost_ping" {
+		c.logger().warn("Health check PING timed out.")
+		c.lostPingOnce.Do(func() { close(c.lostPing) })
+	}
+}
+
+// pingFailed returns a channel that is closed when client's connection
+// is lost due to a failed health check PING.
+func (c *HTTPClient) pingFailed() <-chan struct{} {
+	return c.lostPing
+}
+
 func (c *HTTPClient) init() {
 	c.cond = sync.NewCond(&c.mu)
 	c.effCap = 1 // assume just 1 until connection is open
@@ -129,7 +163,41 @@ func (c *HTTPClient) getClientConn() (*http2.ClientConn, error) {
 		// http2 incursion is disabled, so this it not an error
 		return nil, nil
 	}
-	return http2x.GetClientConn(c.connPool, c.addr)
+	res, err := http2x.GetClientConn(c.connPool, c.addr)
+	if err == nil {
+		c.mu.Lock()
+		if c.conn == nil {
+			c.conn = res
+		}
+		c.mu.Unlock()
+	}
+	return res, err
+}
+
+// goneAway returns true if client's HTTP/2 connection no longer accepts
+// new requests because the server sent GOAWAY or because the connection
+// is closing or closed. A connection that is merely saturated has not gone
+// away. Details of the GOAWAY frame are also returned, if known.
+// The state of the connection can only be examined if HTTP/2 incursion
+// is allowed. Otherwise false is always returned.
+func (c *HTTPClient) goneAway() (bool, *http2.GoAwayError) {
+	c.initOnce.Do(c.init)
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	return connGoneAway(conn)
+}
+
+func connGoneAway(conn *http2.ClientConn) (bool, *http2.GoAwayError) {
+	if conn == nil {
+		return false, nil
+	}
+	if ga := http2x.GetGoAway(conn); ga != nil {
+		return true, ga
+	}
+	// Closing is also set once GOAWAY has been received.
+	st := conn.State()
+	return st.Closing || st.Closed, nil
 }
 
 // ReservedStream returns a reserved HTTP2Stream in the client's
@@ -207,6 +275,37 @@ func (c *HTTPClient) release() {
 	}
 }
 
+// inFlight returns the number of currently reserved streams.
+func (c *HTTPClient) inFlight() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cnt
+}
+
+// streamStats returns the number of currently reserved streams, maximum
+// concurrent streams allowed by the server and effective stream capacity.
+func (c *HTTPClient) streamStats() (cnt uint32, actCap uint32, effCap uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cnt, c.actCap, c.effCap
+}
+
+// setCfgCap changes configured stream concurrency cap and updates
+// effective capacity accordingly.
+func (c *HTTPClient) setCfgCap(v uint32) {
+	c.initOnce.Do(c.init)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfgCap = v
+	if c.connPool != nil {
+		c.refreshCapLocked()
+		return
+	}
+	if c.effCap > v {
+		c.effCap = v
+	}
+}
+
 func (c *HTTPClient) refreshCap() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -221,8 +320,11 @@ func (c *HTTPClient) refreshCapLocked() {
 	if err != nil {
 		return
 	}
+	if c.conn == nil {
+		c.conn = conn
+	}
 	c.actCap = http2x.GetMaxConcurrentStreams(conn)
-	logTrace(0, "HTTClient", "Max streams = %d\n", c.actCap)
+	c.logger().trace(0, "Max streams = %d", c.actCap)
 	v := c.actCap
 	if v > c.cfgCap {
 		v = c.cfgCap
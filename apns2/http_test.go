@@ -3,9 +3,13 @@
 package apns2
 
 import (
+	"net"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
 )
 
 func TestGetClientConnNoHTTP2Incursion(t *testing.T) {
@@ -47,3 +51,66 @@ func TestReservedStreamNoContention(t *testing.T) {
 	st.Close()
 	assert.Equal(t, uint32(0), c.cnt)
 }
+
+func TestHTTPClientHealthCheck(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewHTTPClient(t, s)
+	tr := c.Transport.(*http2.Transport)
+	assert.Equal(t, CommsFast.ReadIdleTimeout, tr.ReadIdleTimeout)
+	assert.Equal(t, CommsFast.PingTimeout, tr.PingTimeout)
+	select {
+	case <-c.pingFailed():
+		t.Fatal("Should not have signaled failed PING")
+	default:
+	}
+	tr.CountError("conn_close_lost_ping")
+	tr.CountError("conn_close_lost_ping")
+	select {
+	case <-c.pingFailed():
+	default:
+		t.Fatal("Should have signaled failed PING")
+	}
+}
+
+func TestConnGoneAway(t *testing.T) {
+	cconn, sconn := net.Pipe()
+	release := make(chan struct{})
+	entered := make(chan struct{}, 1)
+	srv := &http2.Server{MaxConcurrentStreams: 1}
+	go srv.ServeConn(sconn, &http2.ServeConnOpts{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})})
+	cc, err := (&http2.Transport{}).NewClientConn(cconn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	gone, _ := connGoneAway(cc)
+	assert.False(t, gone)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequest("GET", "http://localhost/", nil)
+		if resp, err := cc.RoundTrip(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-entered
+	for i := 0; i < 100 && cc.CanTakeNewRequest(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Saturated connection has not gone away.
+	assert.False(t, cc.CanTakeNewRequest())
+	gone, ga := connGoneAway(cc)
+	assert.False(t, gone)
+	assert.Nil(t, ga)
+	close(release)
+	<-done
+	gone, _ = connGoneAway(cc)
+	assert.False(t, gone)
+	cc.Close()
+	gone, _ = connGoneAway(cc)
+	assert.True(t, gone)
+}
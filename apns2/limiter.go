@@ -0,0 +1,124 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"sync"
+	"time"
+
+	"github.com/baobabus/go-apns/funit"
+)
+
+// tokenBucket is a token bucket rate limiter that allows going into debt.
+// A reservation that exceeds available tokens is granted immediately, but
+// the requester is expected to wait for the debt to be paid off before
+// proceeding. This allows reservations larger than the bucket's capacity.
+type tokenBucket struct {
+	rate  float64 // tokens per second
+	burst float64 // bucket capacity
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a new full token bucket, or nil if rate
+// is not positive.
+func newTokenBucket(rate float64, burst float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 0 {
+		burst = 0
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// reserve takes n tokens from the bucket and returns the amount of time
+// the caller must wait before proceeding.
+func (b *tokenBucket) reserve(n float64, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked(now)
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns n previously reserved tokens to the bucket.
+func (b *tokenBucket) cancel(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += n
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *tokenBucket) refillLocked(now time.Time) {
+	if b.last.IsZero() {
+		b.last = now
+		return
+	}
+	if el := now.Sub(b.last); el > 0 {
+		b.tokens += el.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// rateLimiter strictly enforces notification rate and bandwidth caps.
+type rateLimiter struct {
+	count *tokenBucket // notifications
+	size  *tokenBucket // bits
+}
+
+// newRateLimiter returns a rate limiter enforcing MaxRate and MaxBandwidth
+// of the supplied configuration, or nil if no limits need to be enforced.
+func newRateLimiter(cfg *ProcCfg) *rateLimiter {
+	if !cfg.StrictLimits {
+		return nil
+	}
+	burst := float64(cfg.MaxRateBurst)
+	if burst < 1 {
+		burst = 1
+	}
+	res := &rateLimiter{
+		count: newTokenBucket(float64(cfg.MaxRate*funit.Second), burst),
+		size:  newTokenBucket(float64(cfg.MaxBandwidth*funit.Second/funit.Bit), float64(cfg.MaxBandwidthBurst/funit.Bit)),
+	}
+	if res.count == nil && res.size == nil {
+		return nil
+	}
+	return res
+}
+
+// reserve accounts for a single request of the specified size in bytes
+// and returns the amount of time the caller must wait before sending it.
+func (l *rateLimiter) reserve(size int) time.Duration {
+	now := time.Now()
+	var res time.Duration
+	if l.count != nil {
+		res = l.count.reserve(1, now)
+	}
+	if l.size != nil {
+		if d := l.size.reserve(float64(size)*float64(funit.Byte/funit.Bit), now); d > res {
+			res = d
+		}
+	}
+	return res
+}
+
+// cancel gives back a reservation of the specified size in bytes.
+func (l *rateLimiter) cancel(size int) {
+	if l.count != nil {
+		l.count.cancel(1)
+	}
+	if l.size != nil {
+		l.size.cancel(float64(size) * float64(funit.Byte/funit.Bit))
+	}
+}
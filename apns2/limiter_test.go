@@ -0,0 +1,53 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baobabus/go-apns/funit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	assert.Nil(t, newTokenBucket(0, 10))
+	now := time.Now()
+	b := newTokenBucket(10, 2)
+	// Burst
+	assert.Equal(t, time.Duration(0), b.reserve(1, now))
+	assert.Equal(t, time.Duration(0), b.reserve(1, now))
+	// Debt
+	assert.Equal(t, 100*time.Millisecond, b.reserve(1, now))
+	assert.Equal(t, 200*time.Millisecond, b.reserve(1, now))
+	// Debt is paid off over time
+	now = now.Add(300 * time.Millisecond)
+	assert.Equal(t, 100*time.Millisecond, b.reserve(2, now))
+	b.cancel(2)
+	now = now.Add(100 * time.Millisecond)
+	assert.Equal(t, time.Duration(0), b.reserve(2, now))
+	// Refill is capped by burst
+	now = now.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), b.reserve(2, now))
+	assert.Equal(t, 100*time.Millisecond, b.reserve(1, now))
+	// Reservations larger than burst are allowed
+	now = now.Add(time.Hour)
+	assert.Equal(t, 300*time.Millisecond, b.reserve(5, now))
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	cfg := ProcCfg{MaxRate: 100 / funit.Second, MaxBandwidth: 8 * funit.Kilobit / funit.Second}
+	assert.Nil(t, newRateLimiter(&cfg))
+	cfg.StrictLimits = true
+	l := newRateLimiter(&cfg)
+	if assert.NotNil(t, l) {
+		assert.Equal(t, float64(100), l.count.rate)
+		assert.Equal(t, float64(1), l.count.burst)
+		assert.Equal(t, float64(8000), l.size.rate)
+		assert.Equal(t, float64(0), l.size.burst)
+		// 1000 bytes are sent at 8000 bits per second
+		assert.Equal(t, time.Second, l.reserve(1000))
+	}
+	cfg = ProcCfg{StrictLimits: true}
+	assert.Nil(t, newRateLimiter(&cfg))
+}
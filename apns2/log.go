@@ -4,6 +4,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 )
 
 // Logger interface is extracted from log.Logger to aid in configuring
@@ -69,23 +70,16 @@ func (t Severity) String() string {
 	return severityStrs[t.Bound()]
 }
 
+// name returns lowercase name of the severity for use in structured logs.
+func (t Severity) name() string {
+	return strings.ToLower(strings.TrimSpace(t.String()))
+}
+
 // LogTrace returns a Severity value corresponding to the spcified trace level.
 func LogTrace(traceLevel uint) Severity {
 	return LogInfo + Severity(traceLevel+1)
 }
 
-func logWarn(id string, format string, v ...interface{}) {
-	logTag(id, LogWarn, format, v...)
-}
-
-func logInfo(id string, format string, v ...interface{}) {
-	logTag(id, LogInfo, format, v...)
-}
-
-func logTrace(level uint, id string, format string, v ...interface{}) {
-	logTag(id, LogInfo+Severity(level+1), format, v...)
-}
-
 func logTag(id string, tag Severity, format string, v ...interface{}) {
 	if tag > LogLevel {
 		return
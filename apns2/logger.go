@@ -0,0 +1,215 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Field is a key/value pair attached to a structured log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Keys of the fields attached to log entries by this package.
+const (
+	FieldClient    = "client"
+	FieldComponent = "component"
+	FieldStreamer  = "streamer"
+	FieldApnsID    = "apns_id"
+	FieldTopic     = "topic"
+	FieldStatus    = "status"
+	FieldReason    = "reason"
+	FieldLatency   = "latency"
+	FieldError     = "error"
+)
+
+// StructuredLogger is a leveled logger that accepts key/value fields.
+// Implementations must be safe for concurrent use.
+//
+// Client's processing pipeline logs to the client's Logger, if one is
+// configured, or to package-wide Log at LogLevel otherwise.
+type StructuredLogger interface {
+
+	// Enabled returns true if entries of the specified severity
+	// are to be logged. Entries that are not enabled are never prepared.
+	Enabled(sev Severity) bool
+
+	// Log emits a single log entry.
+	Log(sev Severity, msg string, fields ...Field)
+}
+
+// NewStdLogger returns a StructuredLogger that writes entries of up to
+// the specified severity to l, one line per entry, with fields appended
+// to the message as key=value pairs.
+func NewStdLogger(l Logger, level Severity) StructuredLogger {
+	return &stdLogger{l: l, level: level}
+}
+
+type stdLogger struct {
+	l     Logger
+	level Severity
+}
+
+func (l *stdLogger) Enabled(sev Severity) bool {
+	return sev <= l.level
+}
+
+func (l *stdLogger) Log(sev Severity, msg string, fields ...Field) {
+	var buf bytes.Buffer
+	buf.WriteString(sev.String())
+	buf.WriteString(msg)
+	writeTextFields(&buf, fields)
+	l.l.Print(buf.String())
+}
+
+// NewJSONLogger returns a StructuredLogger that writes entries of up to
+// the specified severity to w as JSON objects, one per line. Each object
+// carries "time", "level" and "msg" keys followed by the entry's fields.
+// Durations are written as fractional seconds and errors as strings.
+func NewJSONLogger(w io.Writer, level Severity) StructuredLogger {
+	return &jsonLogger{w: w, level: level}
+}
+
+type jsonLogger struct {
+	level Severity
+	mu    sync.Mutex
+	w     io.Writer
+}
+
+func (l *jsonLogger) Enabled(sev Severity) bool {
+	return sev <= l.level
+}
+
+func (l *jsonLogger) Log(sev Severity, msg string, fields ...Field) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSONValue(&buf, time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSONValue(&buf, sev.name())
+	buf.WriteString(`,"msg":`)
+	writeJSONValue(&buf, strings.TrimSpace(msg))
+	for _, f := range fields {
+		buf.WriteByte(',')
+		writeJSONValue(&buf, f.Key)
+		buf.WriteByte(':')
+		writeJSONValue(&buf, f.Value)
+	}
+	buf.WriteString("}\n")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(buf.Bytes())
+}
+
+func writeJSONValue(buf *bytes.Buffer, v interface{}) {
+	switch t := v.(type) {
+	case time.Duration:
+		v = t.Seconds()
+	case error:
+		v = t.Error()
+	case fmt.Stringer:
+		v = t.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(b)
+}
+
+func writeTextFields(buf *bytes.Buffer, fields []Field) {
+	for _, f := range fields {
+		buf.WriteByte(' ')
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		s := fmt.Sprint(f.Value)
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	}
+}
+
+// logger emits log entries on behalf of a processing pipeline component.
+// Entries go to out, if set, or to package-wide Log otherwise. A nil logger
+// logs to package-wide Log without any prefix.
+type logger struct {
+	out StructuredLogger
+
+	// id prefixes entries logged to package-wide Log
+	id string
+
+	// fields identifying the component; these are not logged
+	// to package-wide Log as id already identifies the component
+	fields []Field
+}
+
+// newLogger returns a logger for the client with the specified id.
+func newLogger(out StructuredLogger, clientId string) *logger {
+	return &logger{out: out, id: clientId, fields: []Field{{FieldClient, clientId}}}
+}
+
+// with returns a logger for a component of l's client.
+func (l *logger) with(id string, fields ...Field) *logger {
+	res := &logger{id: id}
+	if l != nil {
+		res.out = l.out
+		res.fields = append(res.fields, l.fields...)
+	}
+	res.fields = append(res.fields, fields...)
+	return res
+}
+
+func (l *logger) enabled(sev Severity) bool {
+	if l == nil || l.out == nil {
+		return sev <= LogLevel
+	}
+	return l.out.Enabled(sev)
+}
+
+func (l *logger) log(sev Severity, msg string, fields ...Field) {
+	if !l.enabled(sev) {
+		return
+	}
+	if l == nil || l.out == nil {
+		var buf bytes.Buffer
+		buf.WriteString(msg)
+		writeTextFields(&buf, fields)
+		id := ""
+		if l != nil {
+			id = l.id
+		}
+		logTag(id, sev, "%s", buf.String())
+		return
+	}
+	all := make([]Field, 0, len(l.fields)+len(fields))
+	all = append(all, l.fields...)
+	all = append(all, fields...)
+	l.out.Log(sev, msg, all...)
+}
+
+func (l *logger) logf(sev Severity, format string, v ...interface{}) {
+	if l.enabled(sev) {
+		l.log(sev, fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *logger) warn(format string, v ...interface{}) {
+	l.logf(LogWarn, format, v...)
+}
+
+func (l *logger) info(format string, v ...interface{}) {
+	l.logf(LogInfo, format, v...)
+}
+
+func (l *logger) trace(level uint, format string, v ...interface{}) {
+	l.logf(LogTrace(level), format, v...)
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, LogInfo)
+	assert.True(t, l.Enabled(LogInfo))
+	assert.False(t, l.Enabled(LogTrace(0)))
+	l.Log(LogWarn, "Oops.",
+		Field{FieldClient, "Client"},
+		Field{FieldStatus, 410},
+		Field{FieldLatency, 1500 * time.Millisecond},
+		Field{FieldError, errors.New("boom")},
+	)
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "warning", m["level"])
+	assert.Equal(t, "Oops.", m["msg"])
+	assert.Equal(t, "Client", m["client"])
+	assert.Equal(t, float64(410), m["status"])
+	assert.Equal(t, 1.5, m["latency"])
+	assert.Equal(t, "boom", m["error"])
+	assert.NotEmpty(t, m["time"])
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), LogNotice)
+	assert.False(t, l.Enabled(LogInfo))
+	l.Log(LogNotice, "Hello.", Field{FieldClient, "Client"}, Field{FieldReason, "a b"})
+	assert.Equal(t, "NOTICE Hello. client=Client reason=\"a b\"\n", buf.String())
+}
+
+type captureLogger struct {
+	fields [][]Field
+}
+
+func (l *captureLogger) Enabled(sev Severity) bool { return sev <= LogInfo }
+
+func (l *captureLogger) Log(sev Severity, msg string, fields ...Field) {
+	l.fields = append(l.fields, fields)
+}
+
+func TestLogger_With(t *testing.T) {
+	out := &captureLogger{}
+	l := newLogger(out, "Client").with("Client-Governor", Field{FieldComponent, "governor"})
+	l.info("Running.")
+	l.log(LogInfo, "Done.", Field{FieldStatus, 200})
+	l.trace(0, "Not logged.")
+	assert.Equal(t, [][]Field{
+		{{FieldClient, "Client"}, {FieldComponent, "governor"}},
+		{{FieldClient, "Client"}, {FieldComponent, "governor"}, {FieldStatus, 200}},
+	}, out.fields)
+	var nl *logger
+	assert.Equal(t, LogLevel >= LogInfo, nl.enabled(LogInfo))
+	assert.Equal(t, []Field{{FieldStreamer, "S"}}, nl.with("S", Field{FieldStreamer, "S"}).fields)
+}
+
+func TestClient_Logger(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	var buf syncBuffer
+	c.Logger = NewJSONLogger(&buf, LogTrace(0))
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	c.PushSync(NoContext, testNotif_BadDevice, DefaultSigner)
+	c.Stop()
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, c.Id, m["client"])
+		if m["msg"] == "Push completed." {
+			found = true
+			assert.Equal(t, float64(400), m["status"])
+			assert.Equal(t, ReasonBadDeviceToken, m["reason"])
+			assert.NotNil(t, m["streamer"])
+			assert.NotNil(t, m["latency"])
+		}
+	}
+	assert.True(t, found)
+}
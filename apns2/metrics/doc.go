@@ -0,0 +1,5 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+// Package metrics exposes APNS client metrics in Prometheus text
+// exposition format without depending on Prometheus client library.
+package metrics
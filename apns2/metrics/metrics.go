@@ -0,0 +1,285 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baobabus/go-apns/apns2"
+)
+
+// ContentType is the content type of the rendered metrics.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Exporter is an http.Handler that renders metrics of registered clients.
+// All metrics are labeled by client's Id, so each registered client
+// must have a distinct Id. It is safe to use an Exporter in concurrent
+// goroutines and to register and unregister clients at any time.
+type Exporter struct {
+	mu      sync.RWMutex
+	clients []*apns2.Client
+}
+
+// NewExporter creates a new Exporter with the supplied clients registered.
+func NewExporter(clients ...*apns2.Client) *Exporter {
+	res := &Exporter{}
+	for _, c := range clients {
+		res.Register(c)
+	}
+	return res
+}
+
+// Register adds the client to the set of clients whose metrics
+// are exported. Registering the same client again has no effect.
+func (e *Exporter) Register(c *apns2.Client) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, v := range e.clients {
+		if v == c {
+			return
+		}
+	}
+	e.clients = append(e.clients, c)
+}
+
+// Unregister removes the client from the set of clients whose metrics
+// are exported.
+func (e *Exporter) Unregister(c *apns2.Client) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, v := range e.clients {
+		if v == c {
+			e.clients = append(e.clients[:i], e.clients[i+1:]...)
+			return
+		}
+	}
+}
+
+// ServeHTTP renders metrics of all registered clients.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	e.WriteTo(w)
+}
+
+// WriteTo renders metrics of all registered clients to w.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.mu.RLock()
+	clients := append([]*apns2.Client(nil), e.clients...)
+	e.mu.RUnlock()
+	snaps := make([]snapshot, len(clients))
+	for i, c := range clients {
+		snaps[i] = snapshot{id: c.Id, stats: c.Stats()}
+	}
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	writeMetrics(cw, snaps, time.Now())
+	err := bw.Flush()
+	if err == nil {
+		err = cw.err
+	}
+	return cw.n, err
+}
+
+type snapshot struct {
+	id    string
+	stats *apns2.Stats
+}
+
+type metric struct {
+	name  string
+	help  string
+	typ   string
+	write func(w io.Writer, name string, s snapshot)
+}
+
+var metrics = []metric{
+	{"apns2_requests_queued", "Number of requests buffered in client's queue.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id), float64(s.stats.Queued))
+		}},
+	{"apns2_requests_submitted_total", "Number of requests dispatched to streamers, including retries.", "counter",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id), float64(s.stats.Submitted))
+		}},
+	{"apns2_requests_in_flight", "Number of requests holding an HTTP/2 stream.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id), float64(s.stats.InFlight))
+		}},
+	{"apns2_requests_parked", "Number of requests awaiting a retry.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id), float64(s.stats.Parked))
+		}},
+	{"apns2_requests_retried_total", "Number of scheduled retry attempts.", "counter",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id), float64(s.stats.Retried))
+		}},
+	{"apns2_results_total", "Number of completed requests by topic, status and rejection reason.", "counter",
+		func(w io.Writer, n string, s snapshot) {
+			keys := make([]apns2.ResultKey, 0, len(s.stats.Results))
+			for k, _ := range s.stats.Results {
+				keys = append(keys, k)
+			}
+			sort.Sort(byResultKey(keys))
+			for _, k := range keys {
+				status := "error"
+				if k.StatusCode != 0 {
+					status = strconv.Itoa(k.StatusCode)
+				}
+				writeSample(w, n, labels("client", s.id, "topic", k.Topic, "status", status, "reason", k.Reason), float64(s.stats.Results[k]))
+			}
+		}},
+	{"apns2_roundtrip_latency_seconds", "Round trip time of requests that received a response.", "histogram",
+		func(w io.Writer, n string, s snapshot) {
+			h := s.stats.Latency
+			var cum uint64
+			for i, b := range h.Bounds {
+				cum += h.Counts[i]
+				writeSample(w, n+"_bucket", labels("client", s.id, "le", formatFloat(b.Seconds())), float64(cum))
+			}
+			writeSample(w, n+"_bucket", labels("client", s.id, "le", "+Inf"), float64(h.Count))
+			writeSample(w, n+"_sum", labels("client", s.id), h.Sum.Seconds())
+			writeSample(w, n+"_count", labels("client", s.id), float64(h.Count))
+		}},
+	{"apns2_streamers", "Number of streamers by state.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id, "state", "active"), float64(len(s.stats.Streamers)))
+			writeSample(w, n, labels("client", s.id, "state", "launching"), float64(s.stats.Launching))
+			writeSample(w, n, labels("client", s.id, "state", "winding_down"), float64(s.stats.WindingDown))
+		}},
+	{"apns2_streamer_streams_in_flight", "Number of reserved HTTP/2 streams per streamer.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			for _, st := range s.stats.Streamers {
+				writeSample(w, n, labels("client", s.id, "streamer", st.Id), float64(st.InFlight))
+			}
+		}},
+	{"apns2_streamer_stream_cap", "Number of concurrent HTTP/2 streams a streamer is allowed to use.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			for _, st := range s.stats.Streamers {
+				writeSample(w, n, labels("client", s.id, "streamer", st.Id), float64(st.StreamCap))
+			}
+		}},
+	{"apns2_streamer_max_concurrent_streams", "MAX_CONCURRENT_STREAMS setting negotiated with APN server.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			for _, st := range s.stats.Streamers {
+				writeSample(w, n, labels("client", s.id, "streamer", st.Id), float64(st.MaxConcurrentStreams))
+			}
+		}},
+	{"apns2_dial_failures_total", "Number of failed streamer launches.", "counter",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id), float64(s.stats.DialFailures))
+		}},
+	{"apns2_goaways_total", "Number of streamer connections found to be going away.", "counter",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id), float64(s.stats.GoAways))
+		}},
+	{"apns2_scale_events_total", "Number of scaling events by direction.", "counter",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id, "direction", "up"), float64(s.stats.ScaleUps))
+			writeSample(w, n, labels("client", s.id, "direction", "down"), float64(s.stats.WindDowns))
+		}},
+	{"apns2_paused", "Whether client's processing is paused.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			v := 0.0
+			if s.stats.Paused {
+				v = 1
+			}
+			writeSample(w, n, labels("client", s.id), v)
+		}},
+	{"apns2_failing_fast", "Whether pushes are failing fast due to prolonged outage.", "gauge",
+		func(w io.Writer, n string, s snapshot) {
+			v := 0.0
+			if s.stats.FailingFast {
+				v = 1
+			}
+			writeSample(w, n, labels("client", s.id), v)
+		}},
+	{"apns2_observer_events_dropped_total", "Number of events dropped by a lagging observer.", "counter",
+		func(w io.Writer, n string, s snapshot) {
+			writeSample(w, n, labels("client", s.id), float64(s.stats.DroppedEvents))
+		}},
+}
+
+func writeMetrics(w io.Writer, snaps []snapshot, now time.Time) {
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for _, s := range snaps {
+			m.write(w, m.name, s)
+		}
+	}
+	// Remaining back-off depends on the time of rendering.
+	n := "apns2_dial_backoff_seconds"
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", n, "Remaining time of the current dial back-off.", n, "gauge")
+	for _, s := range snaps {
+		v := s.stats.BackOffUntil.Sub(now).Seconds()
+		if v < 0 {
+			v = 0
+		}
+		writeSample(w, n, labels("client", s.id), v)
+	}
+	n = "apns2_outage_seconds"
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", n, "Duration of the ongoing period with no connections to APN service.", n, "gauge")
+	for _, s := range snaps {
+		v := 0.0
+		if !s.stats.OutageSince.IsZero() {
+			v = now.Sub(s.stats.OutageSince).Seconds()
+		}
+		writeSample(w, n, labels("client", s.id), v)
+	}
+}
+
+func writeSample(w io.Writer, name string, lbls string, v float64) {
+	fmt.Fprintf(w, "%s{%s} %s\n", name, lbls, formatFloat(v))
+}
+
+// labels renders label name and value pairs.
+func labels(kvs ...string) string {
+	parts := make([]string, 0, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		parts = append(parts, kvs[i]+`="`+labelEscaper.Replace(kvs[i+1])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type byResultKey []apns2.ResultKey
+
+func (a byResultKey) Len() int      { return len(a) }
+func (a byResultKey) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byResultKey) Less(i, j int) bool {
+	switch {
+	case a[i].Topic != a[j].Topic:
+		return a[i].Topic < a[j].Topic
+	case a[i].StatusCode != a[j].StatusCode:
+		return a[i].StatusCode < a[j].StatusCode
+	}
+	return a[i].Reason < a[j].Reason
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baobabus/go-apns/apns2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabels(t *testing.T) {
+	assert.Equal(t, ``, labels())
+	assert.Equal(t, `a="1"`, labels("a", "1"))
+	assert.Equal(t, `a="1",b="x\\y\"z\n"`, labels("a", "1", "b", "x\\y\"z\n"))
+}
+
+func TestWriteMetrics(t *testing.T) {
+	now := time.Now()
+	st := &apns2.Stats{
+		Queued:    3,
+		Submitted: 10,
+		Results: map[apns2.ResultKey]uint64{
+			apns2.ResultKey{Topic: "com.example.b", StatusCode: 200}:                                   7,
+			apns2.ResultKey{Topic: "com.example.a", StatusCode: 410, Reason: apns2.ReasonUnregistered}: 2,
+			apns2.ResultKey{Topic: "com.example.a"}:                                                    1,
+		},
+		Latency: apns2.Histogram{
+			Bounds: []time.Duration{10 * time.Millisecond, time.Second},
+			Counts: []uint64{4, 5, 1},
+			Count:  10,
+			Sum:    2500 * time.Millisecond,
+		},
+		Streamers:    []apns2.StreamerStats{{Id: "C-Streamer-0", InFlight: 2, MaxConcurrentStreams: 1000, StreamCap: 500}},
+		BackOffUntil: now.Add(1500 * time.Millisecond),
+		ScaleUps:     2,
+	}
+	var buf bytes.Buffer
+	writeMetrics(&buf, []snapshot{{id: "C", stats: st}}, now)
+	out := buf.String()
+	for _, exp := range []string{
+		"# TYPE apns2_requests_queued gauge\napns2_requests_queued{client=\"C\"} 3\n",
+		"apns2_requests_submitted_total{client=\"C\"} 10\n",
+		"apns2_results_total{client=\"C\",topic=\"com.example.a\",status=\"error\",reason=\"\"} 1\n" +
+			"apns2_results_total{client=\"C\",topic=\"com.example.a\",status=\"410\",reason=\"Unregistered\"} 2\n" +
+			"apns2_results_total{client=\"C\",topic=\"com.example.b\",status=\"200\",reason=\"\"} 7\n",
+		"# TYPE apns2_roundtrip_latency_seconds histogram\n" +
+			"apns2_roundtrip_latency_seconds_bucket{client=\"C\",le=\"0.01\"} 4\n" +
+			"apns2_roundtrip_latency_seconds_bucket{client=\"C\",le=\"1\"} 9\n" +
+			"apns2_roundtrip_latency_seconds_bucket{client=\"C\",le=\"+Inf\"} 10\n" +
+			"apns2_roundtrip_latency_seconds_sum{client=\"C\"} 2.5\n" +
+			"apns2_roundtrip_latency_seconds_count{client=\"C\"} 10\n",
+		"apns2_streamers{client=\"C\",state=\"active\"} 1\n",
+		"apns2_streamer_max_concurrent_streams{client=\"C\",streamer=\"C-Streamer-0\"} 1000\n",
+		"apns2_scale_events_total{client=\"C\",direction=\"up\"} 2\n",
+		"apns2_dial_backoff_seconds{client=\"C\"} 1.5\n",
+	} {
+		assert.Contains(t, out, exp)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	c1 := &apns2.Client{Id: "C1"}
+	c2 := &apns2.Client{Id: "C2"}
+	e := NewExporter(c1, c2, c1)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	out := rec.Body.String()
+	assert.Equal(t, 1, strings.Count(out, `apns2_requests_queued{client="C1"}`))
+	assert.Equal(t, 1, strings.Count(out, `apns2_requests_queued{client="C2"}`))
+	e.Unregister(c1)
+	var buf bytes.Buffer
+	e.WriteTo(&buf)
+	assert.NotContains(t, buf.String(), `client="C1"`)
+	assert.Contains(t, buf.String(), `client="C2"`)
+}
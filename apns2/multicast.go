@@ -0,0 +1,182 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Recipients is an iterator over device tokens of multicast push recipients.
+type Recipients interface {
+
+	// Next returns the next device token and true, or an empty string
+	// and false if there are no more recipients.
+	Next() (string, bool)
+}
+
+// RecipientSlice is a Recipients iterator over a slice of device tokens.
+// Iteration consumes the slice.
+type RecipientSlice []string
+
+// Next returns the first token in the slice and advances the slice past it.
+func (s *RecipientSlice) Next() (string, bool) {
+	if len(*s) == 0 {
+		return "", false
+	}
+	res := (*s)[0]
+	*s = (*s)[1:]
+	return res, true
+}
+
+// RecipientChan is a Recipients iterator over device tokens received
+// from a channel. Iteration ends when the channel is closed.
+type RecipientChan <-chan string
+
+// Next receives the next token from the channel.
+func (c RecipientChan) Next() (string, bool) {
+	res, ok := <-c
+	return res, ok
+}
+
+// MulticastReport is the aggregated outcome of a multicast push.
+type MulticastReport struct {
+
+	// Submitted is the number of notifications submitted for processing.
+	Submitted int
+
+	// Accepted is the number of notifications accepted by APN service.
+	Accepted int
+
+	// Failed is the number of notifications that failed without
+	// a response from APN service.
+	Failed int
+
+	// ByStatus holds the number of APN service responses by status code.
+	ByStatus map[int]int
+
+	// ByReason holds the number of rejections by rejection reason.
+	ByReason map[string]int
+
+	// InvalidTokens lists the device tokens that APN service reported
+	// as unregistered or bad. These should no longer be pushed to.
+	InvalidTokens []string
+
+	// ExhaustedTokens lists the device tokens for which pushes failed
+	// with retriable errors after exhausting allowed retry attempts.
+	// Pushes that were not retried at all, for example because MaxRetries
+	// is zero, are not included.
+	ExhaustedTokens []string
+}
+
+func (r *MulticastReport) add(res *Result, cfg *ProcCfg) {
+	if res.IsAccepted() {
+		r.Accepted++
+	}
+	if resp := res.Response; resp != nil {
+		r.ByStatus[resp.StatusCode]++
+		if resp.RejectionReason != "" {
+			r.ByReason[resp.RejectionReason]++
+		}
+		switch resp.RejectionReason {
+		case ReasonUnregistered, ReasonBadDeviceToken:
+			r.InvalidTokens = append(r.InvalidTokens, res.Notification.Recipient)
+			return
+		}
+	} else if res.Err != nil {
+		r.Failed++
+	}
+	if !res.IsAccepted() && res.Attempts > 1 && cfg.isRetriable(res.Response, res.Err) {
+		r.ExhaustedTokens = append(r.ExhaustedTokens, res.Notification.Recipient)
+	}
+}
+
+// PushMulticast sends the same notification to all recipients and blocks
+// until the outcome of every push is known. Template notification supplies
+// the header and the payload to be shared by all pushes. Its ApnsID and
+// Recipient are ignored.
+//
+// Signer has the same semantics as in Push method and is used for all pushes.
+//
+// The template's payload is serialized only once and the same header
+// is used with every push, so the template must not be modified while
+// the multicast is in progress.
+//
+// Context carries a deadline and a cancellation signal for the entire
+// multicast. Once it is canceled no further pushes are initiated and any
+// pending ones are abandoned as per context handling in Push method.
+//
+// The returned report accounts for all submitted pushes. If the multicast
+// could not be completed, the report is partial and a non-nil error
+// is returned.
+func (c *Client) PushMulticast(ctx context.Context, template *Notification, recipients Recipients, signer RequestSigner) (*MulticastReport, error) {
+	res := &MulticastReport{
+		ByStatus: make(map[int]int),
+		ByReason: make(map[string]int),
+	}
+	body, err := template.payloadBytes()
+	if err != nil {
+		return res, err
+	}
+	var ctxDone <-chan struct{}
+	if ctx != NoContext {
+		ctxDone = ctx.Done()
+	}
+	cb := make(chan *Result, 100)
+	submitted := make(chan int, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	var perr error
+	// Running count of submitted pushes, for when the total never arrives.
+	var cnt int64
+	// Pushes may block, so they are done in their own goroutine.
+	go func() {
+		defer func() { submitted <- int(atomic.LoadInt64(&cnt)) }()
+		for {
+			select {
+			case <-ctxDone:
+				perr = ErrCanceled
+				return
+			case <-stop:
+				return
+			default:
+			}
+			rcpt, ok := recipients.Next()
+			if !ok {
+				return
+			}
+			n := &Notification{
+				Recipient: rcpt,
+				Header:    template.Header,
+				Payload:   body,
+			}
+			if perr = c.Push(n, signer, ctx, cb); perr != nil {
+				return
+			}
+			atomic.AddInt64(&cnt, 1)
+		}
+	}()
+	c.mu.RLock()
+	ctl := c.ctl
+	c.mu.RUnlock()
+	cfg := c.procCfg()
+	received := 0
+	for res.Submitted = -1; res.Submitted < 0 || received < res.Submitted; {
+		select {
+		case r := <-cb:
+			res.add(r, cfg)
+			received++
+		case res.Submitted = <-submitted:
+			if perr != nil {
+				err = perr
+			}
+		case <-ctl:
+			// Hard shutdown; pending results are never going to arrive.
+			if res.Submitted < 0 {
+				res.Submitted = int(atomic.LoadInt64(&cnt))
+			}
+			return res, ErrPushInterrupted
+		}
+	}
+	return res, err
+}
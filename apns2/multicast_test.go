@@ -0,0 +1,106 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/baobabus/go-apnsmock/apns2mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecipientSlice(t *testing.T) {
+	s := RecipientSlice([]string{"a", "b"})
+	var rs Recipients = &s
+	r, ok := rs.Next()
+	assert.True(t, ok)
+	assert.Equal(t, "a", r)
+	r, ok = rs.Next()
+	assert.True(t, ok)
+	assert.Equal(t, "b", r)
+	r, ok = rs.Next()
+	assert.False(t, ok)
+	assert.Equal(t, "", r)
+}
+
+func TestRecipientChan(t *testing.T) {
+	c := make(chan string, 2)
+	c <- "a"
+	close(c)
+	var rs Recipients = RecipientChan(c)
+	r, ok := rs.Next()
+	assert.True(t, ok)
+	assert.Equal(t, "a", r)
+	_, ok = rs.Next()
+	assert.False(t, ok)
+}
+
+func TestClient_PushMulticast(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	err := c.Start(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	rs := RecipientSlice([]string{
+		testNotif_Good.Recipient,
+		testNotif_BadDevice.Recipient,
+		testNotif_Good.Recipient,
+	})
+	r, err := c.PushMulticast(context.Background(), testNotif_Good, &rs, DefaultSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, r.Submitted)
+	assert.Equal(t, 2, r.Accepted)
+	assert.Equal(t, 0, r.Failed)
+	assert.Equal(t, map[int]int{200: 2, 400: 1}, r.ByStatus)
+	assert.Equal(t, map[string]int{ReasonBadDeviceToken: 1}, r.ByReason)
+	assert.Equal(t, []string{testNotif_BadDevice.Recipient}, r.InvalidTokens)
+	assert.Empty(t, r.ExhaustedTokens)
+}
+
+func TestClient_PushMulticast_Killed(t *testing.T) {
+	s := mustNewMockServerWithCfg(t, apns2mock.CommsCfg{
+		MaxConcurrentStreams: 500,
+		MaxConns:             1000,
+		ResponseTime:         time.Second,
+	})
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	// Recipients are never exhausted, so the total is never known.
+	// With streams capped at one, one push is sent and the other one waits
+	// for an available stream. Both can be under way otherwise.
+	rc := make(chan string, 2)
+	defer close(rc)
+	for i := 0; i < 2; i++ {
+		rc <- testNotif_Good.Recipient
+	}
+	go func() {
+		// Let the pushes get under way.
+		time.Sleep(100 * time.Millisecond)
+		c.Kill()
+	}()
+	r, err := c.PushMulticast(NoContext, testNotif_Good, RecipientChan(rc), DefaultSigner)
+	assert.Equal(t, ErrPushInterrupted, err)
+	assert.Equal(t, 2, r.Submitted)
+	assert.Equal(t, 0, r.Accepted)
+}
+
+func TestMulticastReport_ExhaustedTokens(t *testing.T) {
+	r := &MulticastReport{ByStatus: make(map[int]int), ByReason: make(map[string]int)}
+	cfg := &ProcCfg{MaxRetries: 2}
+	resp := &Response{StatusCode: 500, RejectionReason: ReasonInternalServerError}
+	r.add(&Result{Notification: &Notification{Recipient: "a"}, Response: resp, Attempts: 1}, cfg)
+	r.add(&Result{Notification: &Notification{Recipient: "b"}, Response: resp, Attempts: 3}, cfg)
+	assert.Equal(t, []string{"b"}, r.ExhaustedTokens)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -30,6 +31,118 @@ const (
 	PriorityHigh = 10
 )
 
+// PushType is the type of the notification, which is sent to APNs
+// in apns-push-type header. It is required for watchOS 6 and later,
+// and recommended for iOS 13 and later. The value must accurately reflect
+// the contents of the notification's payload.
+type PushType string
+
+const (
+	// PushTypeAlert is used for notifications that trigger a user
+	// interaction, such as an alert, badge or sound.
+	PushTypeAlert PushType = "alert"
+
+	// PushTypeBackground is used for notifications that deliver content
+	// in the background and don't trigger any user interactions.
+	// Priority must be set to PriorityLow.
+	PushTypeBackground PushType = "background"
+
+	// PushTypeLocation is used for notifications that request a user's
+	// location. The topic must end with ".location-query".
+	PushTypeLocation PushType = "location"
+
+	// PushTypeVoIP is used for notifications that provide information about
+	// an incoming Voice-over-IP call. The topic must end with ".voip".
+	PushTypeVoIP PushType = "voip"
+
+	// PushTypeComplication is used for notifications that contain update
+	// information for a watchOS app's complications. The topic must end
+	// with ".complication".
+	PushTypeComplication PushType = "complication"
+
+	// PushTypeFileProvider is used to signal changes to a File Provider
+	// extension. The topic must end with ".pushkit.fileprovider".
+	PushTypeFileProvider PushType = "fileprovider"
+
+	// PushTypeMDM is used for notifications that tell managed devices
+	// to contact the MDM server. The topic is the one from the UID attribute
+	// in the subject of the MDM push certificate.
+	PushTypeMDM PushType = "mdm"
+
+	// PushTypeLiveActivity is used for notifications that start, update
+	// or end a Live Activity. The topic must end with
+	// ".push-type.liveactivity". See APS Event for payload fields.
+	PushTypeLiveActivity PushType = "liveactivity"
+
+	// PushTypePushToTalk is used for notifications that provide information
+	// about an incoming Push to Talk transmission. The topic must end
+	// with ".voip-ptt" and Priority must not be PriorityLow.
+	PushTypePushToTalk PushType = "pushtotalk"
+
+	// PushTypeWidgets is used for notifications that reload widgets.
+	// The topic must end with ".push-type.widgets".
+	PushTypeWidgets PushType = "widgets"
+
+	// PushTypeControls is used for notifications that reload controls.
+	// The topic must end with ".push-type.controls".
+	PushTypeControls PushType = "controls"
+)
+
+// Maximum payload sizes accepted by APNs, in bytes.
+const (
+	// MaxPayloadSize applies to all push types except VoIP.
+	MaxPayloadSize = 4096
+
+	// MaxVoIPPayloadSize applies to VoIP push type.
+	MaxVoIPPayloadSize = 5120
+)
+
+// MaxPayloadSize returns the maximum payload size in bytes that APNs accepts
+// for the push type.
+func (t PushType) MaxPayloadSize() int {
+	if t == PushTypeVoIP {
+		return MaxVoIPPayloadSize
+	}
+	return MaxPayloadSize
+}
+
+// pushTypeTopicSuffixes lists topic suffixes required by push types.
+var pushTypeTopicSuffixes = map[PushType]string{
+	PushTypeLocation:     ".location-query",
+	PushTypeVoIP:         ".voip",
+	PushTypeComplication: ".complication",
+	PushTypeFileProvider: ".pushkit.fileprovider",
+	PushTypeLiveActivity: ".push-type.liveactivity",
+	PushTypePushToTalk:   ".voip-ptt",
+	PushTypeWidgets:      ".push-type.widgets",
+	PushTypeControls:     ".push-type.controls",
+}
+
+// HeaderError indicates a combination of header values that would be
+// rejected by APN service.
+type HeaderError struct {
+	PushType PushType
+	Reason   string
+}
+
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("apns2: invalid header for push type %q: %s", e.PushType, e.Reason)
+}
+
+// PayloadSizeError indicates that notification's payload is larger than APNs
+// accepts for its push type. Such notifications are failed without being sent.
+type PayloadSizeError struct {
+	PushType PushType
+	// Size is the size of the encoded payload in bytes.
+	Size int
+	// MaxSize is the maximum size allowed for the push type.
+	MaxSize int
+}
+
+func (e *PayloadSizeError) Error() string {
+	return fmt.Sprintf("apns2: payload size of %d bytes exceeds the limit of %d bytes", e.Size, e.MaxSize)
+}
+
 // Notification holds the data that is to be pushed to the recipient
 // as well as any routing information required to deliver it.
 // Routing headers and the notification payload are meant to remain immutable
@@ -95,15 +208,29 @@ type Header struct {
 	// and does not store the notification or attempt to redeliver it.
 	Expiration time.Time
 
+	// PushType is the type of the notification. It must accurately reflect
+	// the contents of the payload. If not set, no apns-push-type header
+	// is sent, which is only acceptable to APNs for alert and background
+	// notifications to devices running iOS 12 and earlier.
+	PushType PushType
+
+	// cached HTTP headers and validation outcome, *httpHeaders
 	httpHeaders atomic.Value
 }
 
+type httpHeaders struct {
+	hdrs [][2]string
+	err  error
+}
+
 func (n *Notification) write(r *http.Request) error {
 	r.Header.Set("Content-Type", "application/json; charset=utf-8")
 	if n.ApnsID != "" {
 		r.Header.Set("apns-id", n.ApnsID)
 	}
-	n.Header.write(r)
+	if err := n.Header.write(r); err != nil {
+		return err
+	}
 	body, err := n.newPayloadReader()
 	if err != nil {
 		return err
@@ -117,35 +244,78 @@ func (n *Notification) write(r *http.Request) error {
 	return nil
 }
 
+// newPayloadReader returns a reader of notification's payload, or
+// a *PayloadSizeError if the payload is too large for notification's
+// push type.
 func (n *Notification) newPayloadReader() (*sliceReader, error) {
-	var buf []byte
+	buf, err := n.payloadBytes()
+	if err != nil {
+		return nil, err
+	}
+	var pt PushType
+	if n.Header != nil {
+		pt = n.Header.PushType
+	}
+	if max := pt.MaxPayloadSize(); len(buf) > max {
+		return nil, &PayloadSizeError{PushType: pt, Size: len(buf), MaxSize: max}
+	}
+	return newSliceReader(buf), nil
+}
+
+// payloadBytes returns JSON encoding of the notification's payload.
+func (n *Notification) payloadBytes() ([]byte, error) {
 	switch n.Payload.(type) {
 	case []byte:
-		buf = n.Payload.([]byte)
+		return n.Payload.([]byte), nil
 	case string:
-		buf = []byte(n.Payload.(string))
-	default:
-		var err error
-		buf, err = json.Marshal(n.Payload)
-		if err != nil {
-			return nil, err
+		return []byte(n.Payload.(string)), nil
+	}
+	return json.Marshal(n.Payload)
+}
+
+// Validate checks the header for combinations of values that APN service
+// is known to reject, such as a background push with high priority or
+// a VoIP push with a topic that lacks ".voip" suffix. A *HeaderError
+// is returned if any are found. Header of each notification is validated
+// before the notification is sent.
+//
+// Topic suffix requirements are not checked if Topic is not set.
+func (h *Header) Validate() error {
+	return h.getHTTPHeaders().err
+}
+
+func (h *Header) validate() error {
+	switch h.PushType {
+	case PushTypeBackground:
+		if h.Priority != PriorityLow {
+			return &HeaderError{h.PushType, "priority must be 5"}
+		}
+	case PushTypePushToTalk:
+		if h.Priority == PriorityLow {
+			return &HeaderError{h.PushType, "priority must be 10"}
 		}
 	}
-	return newSliceReader(buf), nil
+	if sfx, ok := pushTypeTopicSuffixes[h.PushType]; ok && h.Topic != "" && !strings.HasSuffix(h.Topic, sfx) {
+		return &HeaderError{h.PushType, fmt.Sprintf("topic must end with %q", sfx)}
+	}
+	return nil
 }
 
-func (h *Header) getHTTPHeaders() [][2]string {
+func (h *Header) getHTTPHeaders() *httpHeaders {
 	res := h.httpHeaders.Load()
 	if res != nil {
-		return res.([][2]string)
+		return res.(*httpHeaders)
 	}
 	// We could protect this with a Mutex, but for improved throughput
 	// it is probably better to avoid resource contention here and just
 	// duplicate the work in case we have concurrent calls.
-	hdrs := make([][2]string, 0, 4)
+	hdrs := make([][2]string, 0, 5)
 	if h.Topic != "" {
 		hdrs = append(hdrs, [...]string{"apns-topic", h.Topic})
 	}
+	if h.PushType != "" {
+		hdrs = append(hdrs, [...]string{"apns-push-type", string(h.PushType)})
+	}
 	if h.CollapseID != "" {
 		hdrs = append(hdrs, [...]string{"apns-collapse-id", h.CollapseID})
 	}
@@ -155,12 +325,17 @@ func (h *Header) getHTTPHeaders() [][2]string {
 	if !h.Expiration.IsZero() {
 		hdrs = append(hdrs, [...]string{"apns-expiration", fmt.Sprintf("%v", h.Expiration.Unix())})
 	}
-	h.httpHeaders.Store(hdrs)
-	return hdrs
+	hh := &httpHeaders{hdrs: hdrs, err: h.validate()}
+	h.httpHeaders.Store(hh)
+	return hh
 }
 
 func (h *Header) write(r *http.Request) error {
-	for _, h := range h.getHTTPHeaders() {
+	hh := h.getHTTPHeaders()
+	if hh.err != nil {
+		return hh.err
+	}
+	for _, h := range hh.hdrs {
 		r.Header.Set(h[0], h[1])
 	}
 	return nil
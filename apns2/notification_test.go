@@ -0,0 +1,111 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader_PushType(t *testing.T) {
+	h := &Header{Topic: "com.example.App", PushType: PushTypeAlert, Priority: PriorityHigh}
+	r, _ := http.NewRequest("POST", "https://localhost", nil)
+	assert.Nil(t, h.write(r))
+	assert.Equal(t, "alert", r.Header.Get("apns-push-type"))
+	assert.Equal(t, "com.example.App", r.Header.Get("apns-topic"))
+	assert.Equal(t, "10", r.Header.Get("apns-priority"))
+	r, _ = http.NewRequest("POST", "https://localhost", nil)
+	assert.Nil(t, (&Header{Topic: "com.example.App"}).write(r))
+	_, ok := r.Header["Apns-Push-Type"]
+	assert.False(t, ok)
+}
+
+func TestHeader_Validate(t *testing.T) {
+	for _, c := range []struct {
+		h  Header
+		ok bool
+	}{
+		{Header{}, true},
+		{Header{PushType: PushTypeAlert}, true},
+		{Header{PushType: PushTypeBackground, Priority: PriorityLow}, true},
+		{Header{PushType: PushTypeBackground}, false},
+		{Header{PushType: PushTypeBackground, Priority: PriorityHigh}, false},
+		{Header{PushType: PushTypeVoIP, Topic: "com.example.App.voip"}, true},
+		{Header{PushType: PushTypeVoIP, Topic: "com.example.App"}, false},
+		{Header{PushType: PushTypeVoIP}, true},
+		{Header{PushType: PushTypeComplication, Topic: "com.example.App.complication"}, true},
+		{Header{PushType: PushTypeComplication, Topic: "com.example.App.voip"}, false},
+		{Header{PushType: PushTypeFileProvider, Topic: "com.example.App.pushkit.fileprovider"}, true},
+		{Header{PushType: PushTypeLocation, Topic: "com.example.App"}, false},
+		{Header{PushType: PushTypePushToTalk, Topic: "com.example.App.voip-ptt"}, true},
+		{Header{PushType: PushTypePushToTalk, Topic: "com.example.App.voip-ptt", Priority: PriorityLow}, false},
+		{Header{PushType: PushTypeMDM, Topic: "com.apple.mgmt.External.abc"}, true},
+		{Header{PushType: PushTypeLiveActivity, Topic: "com.example.App.push-type.liveactivity"}, true},
+		{Header{PushType: PushTypeLiveActivity, Topic: "com.example.App"}, false},
+	} {
+		err := c.h.Validate()
+		if c.ok {
+			assert.Nil(t, err, "%+v", c.h)
+		} else if assert.IsType(t, &HeaderError{}, err, "%+v", c.h) {
+			assert.Equal(t, c.h.PushType, err.(*HeaderError).PushType)
+		}
+	}
+}
+
+func TestNotification_InvalidHeader(t *testing.T) {
+	n := &Notification{
+		Recipient: testNotif_Good.Recipient,
+		Header:    &Header{Topic: "com.example.App", PushType: PushTypeVoIP},
+		Payload:   testNotif_Good.Payload,
+	}
+	r, _ := http.NewRequest("POST", "https://localhost", nil)
+	assert.IsType(t, &HeaderError{}, n.write(r))
+}
+
+func TestNotification_PayloadSize(t *testing.T) {
+	body := strings.Repeat("x", MaxPayloadSize)
+	n := &Notification{
+		Recipient: testNotif_Good.Recipient,
+		Header:    &Header{Topic: "com.example.App", PushType: PushTypeAlert},
+		Payload:   &Payload{APS: &APS{Alert: body}},
+	}
+	r, _ := http.NewRequest("POST", "https://localhost", nil)
+	err := n.write(r)
+	if assert.IsType(t, &PayloadSizeError{}, err) {
+		assert.Equal(t, MaxPayloadSize, err.(*PayloadSizeError).MaxSize)
+		assert.Equal(t, len(body)+len(`{"aps":{"alert":""}}`), err.(*PayloadSizeError).Size)
+	}
+	// VoIP pushes are allowed larger payloads.
+	n.Header = &Header{Topic: "com.example.App.voip", PushType: PushTypeVoIP}
+	assert.Nil(t, n.write(r))
+	n.Payload = strings.Repeat("x", MaxVoIPPayloadSize+1)
+	assert.IsType(t, &PayloadSizeError{}, n.write(r))
+}
+
+func TestClient_PayloadTooLarge(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	n := &Notification{
+		Recipient: testNotif_Good.Recipient,
+		Header:    testNotif_Good.Header,
+		Payload:   &Payload{APS: &APS{Alert: strings.Repeat("x", MaxPayloadSize)}},
+	}
+	res, err := c.PushSync(NoContext, n, DefaultSigner)
+	if assert.IsType(t, &PayloadSizeError{}, err) {
+		assert.Equal(t, MaxPayloadSize, err.(*PayloadSizeError).MaxSize)
+	}
+	if assert.NotNil(t, res) {
+		assert.Nil(t, res.Response)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Observer is notified of notable events in Client's processing pipeline.
+//
+// Observer methods are called serially from a single goroutine dedicated
+// to the client, in the order in which the events occurred. Observer calls
+// never block the processing pipeline. Instead, if the observer falls behind
+// by more than EventQueueSize events, any further events are dropped until
+// the observer catches up. The number of dropped events is reported
+// in client's Stats.
+//
+// Embed NopObserver in your own implementation if you are only interested
+// in some of the events.
+type Observer interface {
+
+	// StreamerLaunched is called when a new streamer has successfully
+	// established its connection and started processing.
+	StreamerLaunched(StreamerEvent)
+
+	// StreamerLaunchFailed is called when a streamer could not be started.
+	StreamerLaunchFailed(StreamerEvent)
+
+	// StreamerQuit is called when a streamer exits due to its connection
+	// having become unusable.
+	StreamerQuit(StreamerEvent)
+
+	// StreamerRetired is called when a streamer that was being wound down
+	// has completed all of its pending requests and exited.
+	StreamerRetired(StreamerEvent)
+
+	// ConnectionUnusable is called when a push attempt's outcome indicates
+	// that streamer's connection can no longer be used.
+	ConnectionUnusable(ConnectionEvent)
+
+	// ConnectionGoingAway is called when a streamer finds that its connection
+	// no longer accepts new requests, normally due to APN server sending
+	// GOAWAY. The streamer stops taking new requests and is retired once its
+	// pending requests complete. A replacement streamer is launched right away.
+	ConnectionGoingAway(GoAwayEvent)
+
+	// ScaleUp is called when the governor decides to launch new streamers.
+	ScaleUp(ScaleEvent)
+
+	// WindDown is called when the governor decides to wind down streamers.
+	WindDown(ScaleEvent)
+
+	// BackOffStarted is called when failed launches put new connection
+	// attempts on hold.
+	BackOffStarted(BackOffEvent)
+
+	// BackOffEnded is called when connection attempts are no longer on hold.
+	BackOffEnded(BackOffEvent)
+
+	// ClientStopping is called when client shutdown is initiated.
+	ClientStopping(ClientEvent)
+}
+
+// EventQueueSize is the maximum number of events that can be pending
+// delivery to an Observer before further events are dropped.
+var EventQueueSize = 256
+
+// Event holds the information common to all Observer events.
+type Event struct {
+
+	// Time is the time at which the event occurred.
+	Time time.Time
+
+	// ClientId is the Id of the client.
+	ClientId string
+}
+
+// StreamerEvent describes a streamer lifecycle event.
+type StreamerEvent struct {
+	Event
+
+	// StreamerId identifies the streamer in log entries.
+	StreamerId string
+
+	// Err is the error that caused the event, if any.
+	Err error
+}
+
+// ConnectionEvent describes a change in streamer's connection state.
+type ConnectionEvent struct {
+	Event
+
+	// StreamerId identifies the streamer in log entries.
+	StreamerId string
+
+	// Response is the APN service response, if any, that triggered the event.
+	Response *Response
+
+	// Err is the error, if any, that triggered the event.
+	Err error
+}
+
+// GoAwayEvent describes a streamer's connection going away.
+type GoAwayEvent struct {
+	Event
+
+	// StreamerId identifies the streamer in log entries.
+	StreamerId string
+
+	// GoAway holds the error code and debug data of the GOAWAY frame sent
+	// by APN server. It is nil if the connection was found by polling to be
+	// closing or closed while the frame's details could not be obtained,
+	// which includes connections that are closing without GOAWAY having
+	// been received.
+	GoAway *http2.GoAwayError
+}
+
+// ScaleEvent describes a scaling decision.
+type ScaleEvent struct {
+	Event
+
+	// From is the number of streamers prior to scaling.
+	From uint32
+
+	// To is the targeted number of streamers.
+	To uint32
+}
+
+// BackOffEvent describes a change in dial back-off state.
+type BackOffEvent struct {
+	Event
+
+	// Until is the end of the back-off window.
+	Until time.Time
+}
+
+// ClientEvent describes a client state change.
+type ClientEvent struct {
+	Event
+
+	// Hard is true if the client is being shut down without waiting
+	// for the processing pipeline to unwind.
+	Hard bool
+}
+
+// NopObserver is an Observer that ignores all events.
+type NopObserver struct{}
+
+func (NopObserver) StreamerLaunched(StreamerEvent)     {}
+func (NopObserver) StreamerLaunchFailed(StreamerEvent) {}
+func (NopObserver) StreamerQuit(StreamerEvent)         {}
+func (NopObserver) StreamerRetired(StreamerEvent)      {}
+func (NopObserver) ConnectionUnusable(ConnectionEvent) {}
+func (NopObserver) ConnectionGoingAway(GoAwayEvent)    {}
+func (NopObserver) ScaleUp(ScaleEvent)                 {}
+func (NopObserver) WindDown(ScaleEvent)                {}
+func (NopObserver) BackOffStarted(BackOffEvent)        {}
+func (NopObserver) BackOffEnded(BackOffEvent)          {}
+func (NopObserver) ClientStopping(ClientEvent)         {}
+
+// eventQueue delivers events to an observer from a dedicated goroutine.
+type eventQueue struct {
+	obs     Observer
+	ch      chan func(Observer)
+	dropped uint64
+}
+
+func newEventQueue(obs Observer) *eventQueue {
+	size := EventQueueSize
+	if size < 1 {
+		size = 1
+	}
+	return &eventQueue{obs: obs, ch: make(chan func(Observer), size)}
+}
+
+// post queues the event for delivery without blocking.
+func (q *eventQueue) post(f func(Observer)) {
+	select {
+	case q.ch <- f:
+	default:
+		atomic.AddUint64(&q.dropped, 1)
+	}
+}
+
+// run delivers queued events until done is closed. Any events that are
+// still queued at that time are delivered before run returns.
+func (q *eventQueue) run(done <-chan struct{}) {
+	for {
+		select {
+		case f := <-q.ch:
+			f(q.obs)
+		case <-done:
+			for {
+				select {
+				case f := <-q.ch:
+					f(q.obs)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// newEvent returns common event data stamped with current time.
+func (c *Client) newEvent() Event {
+	return Event{Time: time.Now(), ClientId: c.Id}
+}
+
+// notify posts an event to client's observer, if there is one.
+func (c *Client) notify(f func(Observer)) {
+	if c.events != nil {
+		c.events.post(f)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+)
+
+type testObserver struct {
+	NopObserver
+	events chan string
+}
+
+func (o *testObserver) StreamerLaunched(StreamerEvent) { o.events <- "launched" }
+func (o *testObserver) ScaleUp(ev ScaleEvent)          { o.events <- "scaleUp" }
+func (o *testObserver) ClientStopping(ev ClientEvent) {
+	if ev.Hard {
+		o.events <- "killing"
+	} else {
+		o.events <- "stopping"
+	}
+}
+
+func TestEventQueue(t *testing.T) {
+	defer func(v int) { EventQueueSize = v }(EventQueueSize)
+	EventQueueSize = 2
+	o := &testObserver{events: make(chan string, 10)}
+	q := newEventQueue(o)
+	for i := 0; i < 3; i++ {
+		q.post(func(o Observer) { o.ScaleUp(ScaleEvent{}) })
+	}
+	assert.Equal(t, uint64(1), q.dropped)
+	done := make(chan struct{})
+	close(done)
+	q.run(done)
+	assert.Equal(t, 2, len(o.events))
+}
+
+func TestClient_Observer(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	o := &testObserver{events: make(chan string, 100)}
+	c.Observer = o
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	c.Stop()
+	var got []string
+	for done := false; !done; {
+		select {
+		case e := <-o.events:
+			got = append(got, e)
+		case <-time.After(100 * time.Millisecond):
+			done = true
+		}
+	}
+	assert.Equal(t, []string{"scaleUp", "launched", "stopping"}, got)
+	assert.Equal(t, uint64(0), c.Stats().DroppedEvents)
+}
+
+type goAwayObserver struct {
+	NopObserver
+	events chan GoAwayEvent
+}
+
+func (o *goAwayObserver) ConnectionGoingAway(ev GoAwayEvent) { o.events <- ev }
+
+func TestClient_GoAway(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	o := &goAwayObserver{events: make(chan GoAwayEvent, 10)}
+	c.Observer = o
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	_, err := c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	assert.Nil(t, err)
+	st := c.Stats()
+	if !assert.Equal(t, 1, len(st.Streamers)) {
+		return
+	}
+	c.gov.mu.Lock()
+	var w *streamer
+	for k, _ := range c.gov.streamers {
+		w = k
+	}
+	c.gov.mu.Unlock()
+	// As if reported by a failed roundtrip.
+	w.goAway <- &http2.GoAwayError{ErrCode: http2.ErrCodeNo, DebugData: "shutdown"}
+	select {
+	case ev := <-o.events:
+		assert.Equal(t, w.id, ev.StreamerId)
+		assert.Equal(t, "shutdown", ev.GoAway.DebugData)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	// Replacement must be able to take requests.
+	_, err = c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	assert.Nil(t, err)
+	// Old streamer exits once it is done with its pending requests.
+	for i := 0; i < 100 && c.Stats().WindingDown > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	st = c.Stats()
+	assert.Equal(t, uint64(1), st.GoAways)
+	if assert.Equal(t, 1, len(st.Streamers)) {
+		assert.NotEqual(t, w.id, st.Streamers[0].Id)
+	}
+}
+
+func TestGoAwayError(t *testing.T) {
+	ga := http2.GoAwayError{LastStreamID: 5, ErrCode: http2.ErrCodeNo}
+	assert.Equal(t, &ga, goAwayError(&url.Error{Op: "Post", URL: "https://localhost", Err: ga}))
+	assert.Equal(t, &ga, goAwayError(ga))
+	assert.Nil(t, goAwayError(nil))
+	assert.Nil(t, goAwayError(io.EOF))
+}
+
+type unusableObserver struct {
+	NopObserver
+	events chan ConnectionEvent
+}
+
+func (o *unusableObserver) ConnectionUnusable(ev ConnectionEvent) { o.events <- ev }
+
+func TestClient_PingFailed(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	o := &unusableObserver{events: make(chan ConnectionEvent, 10)}
+	c.Observer = o
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	_, err := c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	assert.Nil(t, err)
+	c.gov.mu.Lock()
+	var w *streamer
+	for k, _ := range c.gov.streamers {
+		w = k
+	}
+	c.gov.mu.Unlock()
+	// As if reported by HTTP/2 transport.
+	w.httpClient.countError("conn_close_lost_ping")
+	select {
+	case ev := <-o.events:
+		assert.Equal(t, w.id, ev.StreamerId)
+		assert.Equal(t, ErrPingTimeout, ev.Err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	// Replacement must be able to take requests.
+	_, err = c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	assert.Nil(t, err)
+	st := c.Stats()
+	if assert.Equal(t, 1, len(st.Streamers)) {
+		assert.NotEqual(t, w.id, st.Streamers[0].Id)
+	}
+}
+
+type recycleObserver struct {
+	NopObserver
+	events chan string
+}
+
+func (o *recycleObserver) StreamerLaunched(StreamerEvent) { o.events <- "launched" }
+func (o *recycleObserver) StreamerRetired(StreamerEvent)  { o.events <- "retired" }
+
+func TestClient_MaxConnectionAge(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	c.CommsCfg.MaxConnectionAge = 100 * time.Millisecond
+	o := &recycleObserver{events: make(chan string, 100)}
+	c.Observer = o
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	for start := time.Now(); time.Since(start) < 500*time.Millisecond; {
+		_, err := c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+		assert.Nil(t, err)
+		st := c.Stats()
+		// Old streamer is only wound down once its replacement is running.
+		assert.True(t, len(st.Streamers) >= 1)
+		time.Sleep(10 * time.Millisecond)
+	}
+	cnt := map[string]int{}
+	for done := false; !done; {
+		select {
+		case e := <-o.events:
+			cnt[e]++
+		default:
+			done = true
+		}
+	}
+	assert.True(t, cnt["launched"] >= 2)
+	assert.True(t, cnt["retired"] >= 1)
+}
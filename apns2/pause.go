@@ -0,0 +1,83 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"sync"
+)
+
+// flagGate coordinates a state flag, such as whether processing is paused,
+// among processing pipeline components.
+type flagGate struct {
+	mu     sync.Mutex
+	flag   bool
+	change chan struct{} // closed on the next change of the flag
+}
+
+func (g *flagGate) init() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flag = false
+	g.change = make(chan struct{})
+}
+
+// get returns current state of the flag and a channel that is closed
+// when the flag changes.
+func (g *flagGate) get() (bool, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.flag, g.change
+}
+
+// set changes the flag and returns true if it was changed.
+func (g *flagGate) set(flag bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.flag == flag || g.change == nil {
+		return false
+	}
+	g.flag = flag
+	close(g.change)
+	g.change = make(chan struct{})
+	return true
+}
+
+func (g *flagGate) isSet() bool {
+	res, _ := g.get()
+	return res
+}
+
+// Pause temporarily halts processing. Client's submitter and streamers stop
+// taking new requests, which blocks Push calls and builds up back pressure
+// on Queue, while any inflight requests are allowed to complete.
+// Connections to APN servers are kept open and no scaling decisions
+// are made until processing is resumed. Pause has no effect if the client
+// is already paused.
+//
+// A request that is being taken for processing at the time Pause is called
+// may still be sent.
+func (c *Client) Pause() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state < stateStarting || c.state > stateRunning {
+		return ErrClientNotRunning
+	}
+	if c.pause.set(true) {
+		c.log.info("Paused.")
+	}
+	return nil
+}
+
+// Resume resumes processing halted by Pause. Resume has no effect if the client
+// is not paused.
+func (c *Client) Resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state < stateStarting || c.state > stateRunning {
+		return ErrClientNotRunning
+	}
+	if c.pause.set(false) {
+		c.log.info("Resumed.")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/baobabus/go-apnsmock/apns2mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_PauseResume(t *testing.T) {
+	s := mustNewMockServerWithCfg(t, apns2mock.CommsCfg{
+		MaxConcurrentStreams: 500,
+		MaxConns:             1000,
+	})
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	q := make(chan *Request, 10)
+	c.Queue = q
+	cb := make(chan *Result, 10)
+	c.Callback = cb
+	assert.Equal(t, ErrClientNotRunning, c.Pause())
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Kill()
+	// Make sure the pipeline is up and running.
+	q <- &Request{Notification: testNotif_Good}
+	<-cb
+	assert.Nil(t, c.Pause())
+	assert.Nil(t, c.Pause())
+	assert.True(t, c.Stats().Paused)
+	// Let the pipeline components take notice.
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		q <- &Request{Notification: testNotif_Good}
+	}
+	select {
+	case <-cb:
+		t.Fatal("Request processed while paused")
+	case <-time.After(200 * time.Millisecond):
+	}
+	st := c.Stats()
+	assert.Equal(t, 3, st.Queued)
+	assert.Equal(t, uint64(1), st.Submitted)
+	assert.Equal(t, 1, len(st.Streamers))
+	assert.Nil(t, c.Resume())
+	assert.Nil(t, c.Resume())
+	assert.False(t, c.Stats().Paused)
+	for i := 0; i < 3; i++ {
+		select {
+		case res := <-cb:
+			assert.True(t, res.IsAccepted())
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for result")
+		}
+	}
+}
+
+func TestClient_StopContext_Paused(t *testing.T) {
+	s := mustNewMockServerWithCfg(t, apns2mock.CommsCfg{
+		MaxConcurrentStreams: 500,
+		MaxConns:             1000,
+	})
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	q := make(chan *Request, 10)
+	c.Queue = q
+	cb := make(chan *Result, 10)
+	c.Callback = cb
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, c.Pause())
+	for i := 0; i < 3; i++ {
+		q <- &Request{Notification: testNotif_Good}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rep, err := c.StopContext(ctx)
+	assert.Nil(t, err)
+	assert.False(t, rep.Hard)
+	assert.Equal(t, uint64(3), rep.Completed)
+	assert.Equal(t, ErrClientNotRunning, c.Resume())
+	cnt := 0
+	for res := range cb {
+		assert.True(t, res.IsAccepted())
+		cnt++
+	}
+	assert.Equal(t, 3, cnt)
+}
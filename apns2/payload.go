@@ -5,6 +5,7 @@ package apns2
 import (
 	"encoding/json"
 	"sync/atomic"
+	"time"
 )
 
 // Payload is the container for the actual data to be delivered
@@ -27,8 +28,73 @@ type APS struct {
 	Sound            string
 	ThreadID         string
 	URLArgs          []string
+
+	// SoundDict is the sound dictionary, which is needed for critical alerts.
+	// It takes precedence over Sound if set.
+	SoundDict *Sound
+
+	// InterruptionLevel is the importance and delivery timing
+	// of the notification.
+	InterruptionLevel InterruptionLevel
+	// FilterCriteria is the criteria used by the system to determine
+	// whether to show the notification in the current Focus.
+	FilterCriteria string
+	// TargetContentID is the identifier of the window brought forward
+	// when the notification is opened.
+	TargetContentID string
+
+	// Live Activity fields; see PushTypeLiveActivity.
+
+	// Event is the action on the Live Activity, one of LiveActivityStart,
+	// LiveActivityUpdate or LiveActivityEnd.
+	Event LiveActivityEvent
+	// Timestamp is the time at which the content of the update was current.
+	// It is required for all Live Activity events.
+	Timestamp time.Time
+	// ContentState is the updated dynamic content of the Live Activity.
+	// It must match the app's ContentState type when decoded.
+	ContentState interface{}
+	// DismissalDate, if set, is the time at which an ended Live Activity
+	// is removed from the Lock Screen. A time in the past removes it
+	// right away.
+	DismissalDate time.Time
+	// StaleDate, if set, is the time at which the Live Activity
+	// becomes outdated.
+	StaleDate time.Time
+	// RelevanceScore, between 0 and 1 for notifications, determines which
+	// of app's notifications is featured in the notification summary.
+	// For Live Activities it determines which of them is featured
+	// when there are several.
+	RelevanceScore float64
+	// AttributesType and Attributes are the name of the app's
+	// ActivityAttributes type and its static data. They are required
+	// to start a Live Activity with a push-to-start token.
+	AttributesType string
+	Attributes     interface{}
+	// InputPushToken, if set, requests a Live Activity started with
+	// a push-to-start token to generate an update push token.
+	InputPushToken bool
+	// InputPushChannel, if set, subscribes a Live Activity started
+	// with a push-to-start token to the broadcast channel with this ID.
+	InputPushChannel string
 }
 
+// LiveActivityEvent is the action on a Live Activity.
+type LiveActivityEvent string
+
+const (
+	// LiveActivityStart starts a Live Activity. It requires notification
+	// to be sent to a push-to-start token with APS Alert, AttributesType
+	// and Attributes set.
+	LiveActivityStart LiveActivityEvent = "start"
+
+	// LiveActivityUpdate updates the content of a Live Activity.
+	LiveActivityUpdate LiveActivityEvent = "update"
+
+	// LiveActivityEnd ends a Live Activity.
+	LiveActivityEnd LiveActivityEvent = "end"
+)
+
 type Alert struct {
 	Action       string   `json:"action,omitempty"`
 	ActionLocKey string   `json:"action-loc-key,omitempty"`
@@ -40,8 +106,64 @@ type Alert struct {
 	Subtitle     string   `json:"subtitle,omitempty"`
 	TitleLocArgs []string `json:"title-loc-args,omitempty"`
 	TitleLocKey  string   `json:"title-loc-key,omitempty"`
+
+	SubtitleLocArgs []string `json:"subtitle-loc-args,omitempty"`
+	SubtitleLocKey  string   `json:"subtitle-loc-key,omitempty"`
+	SummaryArg      string   `json:"summary-arg,omitempty"`
+	SummaryArgCount int      `json:"summary-arg-count,omitempty"`
+}
+
+// Sound is the sound dictionary of APS.SoundDict.
+type Sound struct {
+	// Critical marks the sound as a critical alert, which requires
+	// the app to be entitled to send critical alerts.
+	Critical bool
+	// Name is the name of a sound file in the app's bundle, or "default".
+	Name string
+	// Volume is the volume of a critical alert between 0 and 1.
+	// It is left to the system if zero.
+	Volume float64
 }
 
+// MarshalJSON encodes the sound dictionary with critical flag
+// as the integer APNs expects.
+func (s Sound) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if s.Critical {
+		m["critical"] = 1
+	}
+	if s.Name != "" {
+		m["name"] = s.Name
+	}
+	if s.Volume != 0 {
+		m["volume"] = s.Volume
+	}
+	return json.Marshal(m)
+}
+
+// InterruptionLevel is the importance and delivery timing of a notification.
+type InterruptionLevel string
+
+const (
+	// InterruptionPassive adds the notification to the notification list
+	// without lighting up the screen or playing a sound.
+	InterruptionPassive InterruptionLevel = "passive"
+
+	// InterruptionActive presents the notification immediately, lights up
+	// the screen and can play a sound. This is the default.
+	InterruptionActive InterruptionLevel = "active"
+
+	// InterruptionTimeSensitive presents the notification immediately
+	// and allows it to break through system notification controls,
+	// such as Focus.
+	InterruptionTimeSensitive InterruptionLevel = "time-sensitive"
+
+	// InterruptionCritical presents the notification immediately, lights up
+	// the screen and bypasses the mute switch to play a sound. It requires
+	// the app to be entitled to send critical alerts.
+	InterruptionCritical InterruptionLevel = "critical"
+)
+
 func (p *Payload) MarshalJSON() ([]byte, error) {
 	res := p.json.Load()
 	if res != nil {
@@ -94,7 +216,9 @@ func (a APS) addToMap(m map[string]interface{}) {
 	if a.MutableContent {
 		m["mutable-content"] = 1
 	}
-	if a.Sound != "" {
+	if a.SoundDict != nil {
+		m["sound"] = a.SoundDict
+	} else if a.Sound != "" {
 		m["sound"] = a.Sound
 	}
 	if a.ThreadID != "" {
@@ -103,4 +227,43 @@ func (a APS) addToMap(m map[string]interface{}) {
 	if len(a.URLArgs) > 0 {
 		m["url-args"] = a.URLArgs
 	}
+	if a.InterruptionLevel != "" {
+		m["interruption-level"] = a.InterruptionLevel
+	}
+	if a.FilterCriteria != "" {
+		m["filter-criteria"] = a.FilterCriteria
+	}
+	if a.TargetContentID != "" {
+		m["target-content-id"] = a.TargetContentID
+	}
+	if a.Event != "" {
+		m["event"] = a.Event
+	}
+	if !a.Timestamp.IsZero() {
+		m["timestamp"] = a.Timestamp.Unix()
+	}
+	if a.ContentState != nil {
+		m["content-state"] = a.ContentState
+	}
+	if !a.DismissalDate.IsZero() {
+		m["dismissal-date"] = a.DismissalDate.Unix()
+	}
+	if !a.StaleDate.IsZero() {
+		m["stale-date"] = a.StaleDate.Unix()
+	}
+	if a.RelevanceScore != 0 {
+		m["relevance-score"] = a.RelevanceScore
+	}
+	if a.AttributesType != "" {
+		m["attributes-type"] = a.AttributesType
+	}
+	if a.Attributes != nil {
+		m["attributes"] = a.Attributes
+	}
+	if a.InputPushToken {
+		m["input-push-token"] = 1
+	}
+	if a.InputPushChannel != "" {
+		m["input-push-channel"] = a.InputPushChannel
+	}
 }
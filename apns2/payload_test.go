@@ -0,0 +1,95 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayload_LiveActivity(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	p := &Payload{APS: &APS{
+		Event:          LiveActivityUpdate,
+		Timestamp:      ts,
+		ContentState:   map[string]interface{}{"score": "2-1"},
+		StaleDate:      ts.Add(time.Hour),
+		RelevanceScore: 75,
+	}}
+	j, err := p.MarshalJSON()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"aps":{
+		"event":"update",
+		"timestamp":1700000000,
+		"content-state":{"score":"2-1"},
+		"stale-date":1700003600,
+		"relevance-score":75
+	}}`, string(j))
+}
+
+func TestPayload_LiveActivity_PushToStart(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	p := &Payload{APS: &APS{
+		Alert:          &Alert{Title: "Kick-off", Body: "The match has started."},
+		Event:          LiveActivityStart,
+		Timestamp:      ts,
+		ContentState:   map[string]interface{}{"score": "0-0"},
+		AttributesType: "MatchAttributes",
+		Attributes:     map[string]interface{}{"home": "A", "away": "B"},
+		InputPushToken: true,
+	}}
+	j, err := p.MarshalJSON()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"aps":{
+		"alert":{"title":"Kick-off","body":"The match has started."},
+		"event":"start",
+		"timestamp":1700000000,
+		"content-state":{"score":"0-0"},
+		"attributes-type":"MatchAttributes",
+		"attributes":{"home":"A","away":"B"},
+		"input-push-token":1
+	}}`, string(j))
+}
+
+func TestPayload_ModernFields(t *testing.T) {
+	p := &Payload{
+		APS: &APS{
+			Alert: &Alert{
+				Title:           "Alarm",
+				SubtitleLocKey:  "SUB_KEY",
+				SubtitleLocArgs: []string{"a", "b"},
+				SummaryArg:      "Kitchen",
+				SummaryArgCount: 2,
+			},
+			Sound:             "default",
+			SoundDict:         &Sound{Critical: true, Name: "alarm.caf", Volume: 0.5},
+			InterruptionLevel: InterruptionCritical,
+			RelevanceScore:    0.8,
+			FilterCriteria:    "home",
+			TargetContentID:   "window-1",
+		},
+		Raw: map[string]interface{}{"custom": "value"},
+	}
+	j, err := p.MarshalJSON()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"aps":{
+		"alert":{
+			"title":"Alarm",
+			"subtitle-loc-key":"SUB_KEY",
+			"subtitle-loc-args":["a","b"],
+			"summary-arg":"Kitchen",
+			"summary-arg-count":2
+		},
+		"sound":{"critical":1,"name":"alarm.caf","volume":0.5},
+		"interruption-level":"critical",
+		"relevance-score":0.8,
+		"filter-criteria":"home",
+		"target-content-id":"window-1"
+	},"custom":"value"}`, string(j))
+	// Plain sound names are still supported.
+	j, err = (&Payload{APS: &APS{Sound: "default"}}).MarshalJSON()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"aps":{"sound":"default"}}`, string(j))
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+// ConfigError indicates an invalid configuration setting.
+type ConfigError struct {
+	// Field is the name of the offending setting, such as "ProcCfg.MaxConns".
+	Field string
+	// Reason describes what is wrong with the setting.
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return "apns2: invalid " + e.Field + ": " + e.Reason
+}
+
+func (c *ProcCfg) validate() error {
+	switch {
+	case c.MaxConns == 0:
+		return &ConfigError{"ProcCfg.MaxConns", "must be positive"}
+	case c.MinConns > c.MaxConns:
+		return &ConfigError{"ProcCfg.MinConns", "must not exceed MaxConns"}
+	case c.Scale == nil && c.MinConns < c.MaxConns:
+		return &ConfigError{"ProcCfg.Scale", "must be set if MinConns is less than MaxConns"}
+	case c.MaxRate < 0:
+		return &ConfigError{"ProcCfg.MaxRate", "must not be negative"}
+	case c.MaxBandwidth < 0:
+		return &ConfigError{"ProcCfg.MaxBandwidth", "must not be negative"}
+	case c.MinRetryBackOff < 0:
+		return &ConfigError{"ProcCfg.MinRetryBackOff", "must not be negative"}
+	case c.MaxRetryBackOff < 0:
+		return &ConfigError{"ProcCfg.MaxRetryBackOff", "must not be negative"}
+	case c.MaxOutage < 0:
+		return &ConfigError{"ProcCfg.MaxOutage", "must not be negative"}
+	}
+	return nil
+}
+
+func (c *CommsCfg) validate() error {
+	switch {
+	case c.MaxConcurrentStreams == 0:
+		return &ConfigError{"CommsCfg.MaxConcurrentStreams", "must be positive"}
+	case c.DialTimeout < 0:
+		return &ConfigError{"CommsCfg.DialTimeout", "must not be negative"}
+	case c.RequestTimeout < 0:
+		return &ConfigError{"CommsCfg.RequestTimeout", "must not be negative"}
+	case c.MinDialBackOff < 0:
+		return &ConfigError{"CommsCfg.MinDialBackOff", "must not be negative"}
+	case c.MaxDialBackOff < 0:
+		return &ConfigError{"CommsCfg.MaxDialBackOff", "must not be negative"}
+	case c.MaxConnectionAge < 0:
+		return &ConfigError{"CommsCfg.MaxConnectionAge", "must not be negative"}
+	}
+	return nil
+}
+
+// newLiveCfg returns configuration snapshot for the supplied settings.
+// Rate limiter of prev, if any, is carried over if strict limits
+// are unchanged, so that its accumulated state is preserved.
+func newLiveCfg(proc ProcCfg, comms CommsCfg, prev *liveCfg) *liveCfg {
+	res := &liveCfg{proc: proc, comms: comms}
+	if prev != nil && prev.proc.StrictLimits == proc.StrictLimits &&
+		prev.proc.MaxRate == proc.MaxRate &&
+		prev.proc.MaxRateBurst == proc.MaxRateBurst &&
+		prev.proc.MaxBandwidth == proc.MaxBandwidth &&
+		prev.proc.MaxBandwidthBurst == proc.MaxBandwidthBurst {
+		res.limiter = prev.limiter
+	} else {
+		res.limiter = newRateLimiter(&res.proc)
+	}
+	return res
+}
+
+// Reconfigure validates the supplied settings and makes them client's
+// ProcCfg and CommsCfg. If the client is running, the settings are put
+// into effect right away: connection limits, scaling, rate limits and retry
+// settings apply to all subsequent processing, new connections are made
+// with the new comms settings, and existing connections adopt the new
+// MaxConcurrentStreams cap. Surplus connections are wound down gracefully
+// if MaxConns is lowered. Other comms settings of existing connections
+// remain unchanged until the connections are replaced.
+//
+// A *ConfigError is returned if any of the settings are invalid,
+// in which case no changes are made. ErrClientNotRunning is returned
+// if the client is stopping or has been stopped.
+func (c *Client) Reconfigure(proc ProcCfg, comms CommsCfg) error {
+	if err := proc.validate(); err != nil {
+		return err
+	}
+	if err := comms.validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state > stateRunning {
+		return ErrClientNotRunning
+	}
+	c.ProcCfg, c.CommsCfg = proc, comms
+	if c.state < stateStarting {
+		return nil
+	}
+	// Governor never takes client's lock, so it is safe to wait for it
+	// while holding the lock. This also keeps concurrent calls in order.
+	r := &reconfRequest{
+		cfg:  newLiveCfg(proc, comms, c.gov.config()),
+		done: make(chan struct{}),
+	}
+	select {
+	case c.gov.reconf <- r:
+	case <-c.cdone:
+		return ErrClientNotRunning
+	}
+	select {
+	case <-r.done:
+	case <-c.cdone:
+		return ErrClientNotRunning
+	}
+	c.log.info("Reconfigured.")
+	return nil
+}
+
+// procCfg returns a copy of client's current processing configuration.
+func (c *Client) procCfg() *ProcCfg {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := c.ProcCfg
+	return &res
+}
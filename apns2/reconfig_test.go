@@ -0,0 +1,83 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baobabus/go-apns/scale"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconfigure_Invalid(t *testing.T) {
+	c := &Client{ProcCfg: MinBlockingProcConfig, CommsCfg: CommsFast}
+	proc := MinBlockingProcConfig
+	proc.MaxConns = 0
+	err := c.Reconfigure(proc, CommsFast)
+	if assert.IsType(t, &ConfigError{}, err) {
+		assert.Equal(t, "ProcCfg.MaxConns", err.(*ConfigError).Field)
+	}
+	proc = MinBlockingProcConfig
+	proc.MinConns = 2
+	err = c.Reconfigure(proc, CommsFast)
+	if assert.IsType(t, &ConfigError{}, err) {
+		assert.Equal(t, "ProcCfg.MinConns", err.(*ConfigError).Field)
+	}
+	comms := CommsFast
+	comms.MaxConcurrentStreams = 0
+	err = c.Reconfigure(MinBlockingProcConfig, comms)
+	if assert.IsType(t, &ConfigError{}, err) {
+		assert.Equal(t, "CommsCfg.MaxConcurrentStreams", err.(*ConfigError).Field)
+	}
+	assert.Equal(t, CommsFast, c.CommsCfg)
+	// Settings of a client that is not yet started are simply replaced.
+	comms.MaxConcurrentStreams = 10
+	assert.Nil(t, c.Reconfigure(MinBlockingProcConfig, comms))
+	assert.Equal(t, uint32(10), c.CommsCfg.MaxConcurrentStreams)
+}
+
+func TestClient_Reconfigure(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	assert.Nil(t, err)
+	proc := c.ProcCfg
+	proc.MinConns = 2
+	proc.MaxConns = 2
+	proc.Scale = scale.Constant
+	comms := c.CommsCfg
+	comms.MaxConcurrentStreams = 7
+	assert.Nil(t, c.Reconfigure(proc, comms))
+	for i := 0; i < 100 && len(c.Stats().Streamers) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 2, len(c.Stats().Streamers))
+	c.gov.mu.Lock()
+	for w, _ := range c.gov.streamers {
+		w.httpClient.mu.Lock()
+		assert.Equal(t, uint32(7), w.httpClient.cfgCap)
+		w.httpClient.mu.Unlock()
+	}
+	c.gov.mu.Unlock()
+	// Surplus streamer is wound down.
+	proc.MinConns = 1
+	proc.MaxConns = 1
+	assert.Nil(t, c.Reconfigure(proc, comms))
+	for i := 0; i < 100 && c.Stats().WindingDown > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	st := c.Stats()
+	assert.Equal(t, 1, len(st.Streamers))
+	assert.Equal(t, 0, st.WindingDown)
+	_, err = c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	assert.Nil(t, err)
+	c.Stop()
+	assert.Equal(t, ErrClientNotRunning, c.Reconfigure(proc, comms))
+}
@@ -4,6 +4,7 @@ package apns2
 
 import (
 	"context"
+	"time"
 )
 
 // Request holds all necessary information needed to submit a notification
@@ -30,6 +31,10 @@ type Request struct {
 	Callback chan<- *Result
 
 	attemptCnt int
+	retryDelay time.Duration
+
+	// whether the request is accounted for as being in processing pipeline
+	dispatched bool
 }
 
 // HasSigner returns true if the request has a custom signer supplied or if
@@ -40,7 +45,14 @@ func (r *Request) HasSigner() bool {
 
 // RequestError indicates a request-level error. This helps distinguishing
 // errors that are only scoped to a single request from those related to wider
-// scope, such as transport layer errors.
+// scope, such as transport layer errors. Validation errors, *HeaderError
+// and *PayloadSizeError, are request-level errors in their own right
+// and are reported as is.
 type RequestError struct {
 	error
 }
+
+// Unwrap returns the underlying error.
+func (e *RequestError) Unwrap() error {
+	return e.error
+}
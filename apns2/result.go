@@ -4,6 +4,7 @@ package apns2
 
 import (
 	"context"
+	"time"
 )
 
 // Result represents the outcome of an asynchronous push operation.
@@ -30,6 +31,14 @@ type Result struct {
 	// Note that nil Err does not necessarily indicate a successful attempt.
 	// You must also examine Response for additional status details.
 	Err error
+
+	// Attempts is the number of times the push was attempted, including
+	// the initial attempt and any retries.
+	Attempts uint32
+
+	// RetryDelay is the back-off delay that preceded the last retry attempt.
+	// It is 0 if the push was not retried.
+	RetryDelay time.Duration
 }
 
 // IsAccepted returns whether or not the notification was accepted by APN service.
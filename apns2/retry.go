@@ -0,0 +1,110 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// RetryPolicy determines retry eligibility of failed push attempts.
+type RetryPolicy interface {
+
+	// RetryLimit returns the maximum number of times a push that failed
+	// with the supplied response and error may be retried. Returning 0
+	// indicates that the failure is not retriable. The maxRetries argument
+	// is the client's configured default, ProcCfg.MaxRetries.
+	RetryLimit(resp *Response, err error, maxRetries uint32) uint32
+}
+
+// StatusRetryPolicy classifies push failures based on APN service status
+// codes and rejection reasons, and on the kind of any transport error.
+//
+// Following failures are treated as retriable: 429 TooManyRequests,
+// 500 InternalServerError, 503 ServiceUnavailable and Shutdown,
+// 400 IdleTimeout and 403 ExpiredProviderToken, as well as connection-level
+// errors, such as GOAWAY, reset streams and dial timeouts. Other timeouts
+// are not retried, as they may occur after the request has been written
+// and retrying could result in a duplicate notification.
+// All other rejections are treated as permanent.
+type StatusRetryPolicy struct {
+
+	// MaxRetries, if not nil, overrides the maximum number of retries
+	// for specific rejection reasons. A zero override disables retries
+	// for the reason. Overrides are not limited to the reasons that
+	// are treated as retriable by default.
+	MaxRetries map[string]uint32
+}
+
+// DefaultRetryPolicy is used when neither ProcCfg.RetryPolicy nor
+// ProcCfg.RetryEval are configured. It has no per-reason overrides.
+var DefaultRetryPolicy = &StatusRetryPolicy{}
+
+// RetryLimit implements RetryPolicy.
+func (p *StatusRetryPolicy) RetryLimit(resp *Response, err error, maxRetries uint32) uint32 {
+	if resp != nil && resp.RejectionReason != "" {
+		if v, ok := p.MaxRetries[resp.RejectionReason]; ok {
+			return v
+		}
+	}
+	if isRetriableFailure(resp, err) {
+		return maxRetries
+	}
+	return 0
+}
+
+func isRetriableFailure(resp *Response, err error) bool {
+	if resp == nil || resp.StatusCode == 0 {
+		return isRetriableError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadRequest:
+		return resp.RejectionReason == ReasonIdleTimeout
+	case http.StatusForbidden:
+		return resp.RejectionReason == ReasonExpiredProviderToken
+	}
+	return false
+}
+
+// isRetriableError returns true if err is a connection-level error.
+func isRetriableError(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	switch err {
+	case nil, ErrCanceled, context.Canceled, context.DeadlineExceeded:
+		return false
+	case ErrRoundTripAborted:
+		// Request may have been delivered.
+		return false
+	case io.EOF, io.ErrUnexpectedEOF:
+		return true
+	}
+	switch e := err.(type) {
+	case *RequestError, *HeaderError, *PayloadSizeError:
+		return false
+	case http2.GoAwayError, http2.StreamError, http2.ConnectionError:
+		return true
+	case *net.OpError:
+		// Request may have been written before a read or write timed out.
+		return e.Op == "dial" || !e.Timeout()
+	case net.Error:
+		if e.Timeout() {
+			// Timeouts, such as that of http.Client, may strike
+			// after the request has been written.
+			return false
+		}
+	}
+	// Lost and unusable connections are only reported as plain errors.
+	return strings.HasPrefix(err.Error(), "http2: client conn")
+}
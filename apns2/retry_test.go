@@ -0,0 +1,92 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+)
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	p := DefaultRetryPolicy
+	tcs := []struct {
+		resp *Response
+		err  error
+		exp  uint32
+	}{
+		{&Response{StatusCode: 200}, nil, 0},
+		{&Response{StatusCode: 400, RejectionReason: ReasonBadDeviceToken}, nil, 0},
+		{&Response{StatusCode: 400, RejectionReason: ReasonIdleTimeout}, nil, 3},
+		{&Response{StatusCode: 403, RejectionReason: ReasonBadCertificate}, nil, 0},
+		{&Response{StatusCode: 403, RejectionReason: ReasonExpiredProviderToken}, nil, 3},
+		{&Response{StatusCode: 410, RejectionReason: ReasonUnregistered}, nil, 0},
+		{&Response{StatusCode: 413, RejectionReason: ReasonPayloadTooLarge}, nil, 0},
+		{&Response{StatusCode: 429, RejectionReason: ReasonTooManyRequests}, nil, 3},
+		{&Response{StatusCode: 500, RejectionReason: ReasonInternalServerError}, nil, 3},
+		{&Response{StatusCode: 503, RejectionReason: ReasonServiceUnavailable}, nil, 3},
+		{&Response{StatusCode: 503, RejectionReason: ReasonShutdown}, nil, 3},
+		{nil, ErrCanceled, 0},
+		{nil, context.Canceled, 0},
+		{nil, &RequestError{errors.New("")}, 0},
+		{&Response{}, &RequestError{errors.New("")}, 0},
+		{nil, &HeaderError{}, 0},
+		{nil, &PayloadSizeError{}, 0},
+		{nil, errors.New("some error"), 0},
+		{nil, io.ErrUnexpectedEOF, 3},
+		{nil, http2.GoAwayError{}, 3},
+		{nil, http2.StreamError{}, 3},
+		{nil, http2.ConnectionError(http2.ErrCodeProtocol), 3},
+		{nil, &url.Error{Err: http2.GoAwayError{}}, 3},
+		{nil, &net.OpError{Err: errors.New("connection reset")}, 3},
+		{nil, errors.New("http2: client connection lost"), 3},
+		{nil, &net.OpError{Op: "dial", Err: timeoutError{}}, 3},
+		{nil, &url.Error{Err: &net.OpError{Op: "dial", Err: timeoutError{}}}, 3},
+		{nil, &net.OpError{Op: "read", Err: timeoutError{}}, 0},
+		{nil, &net.OpError{Op: "write", Err: timeoutError{}}, 0},
+		{nil, timeoutError{}, 0},
+		{nil, &url.Error{Err: timeoutError{}}, 0},
+	}
+	for i, tc := range tcs {
+		assert.Equal(t, tc.exp, p.RetryLimit(tc.resp, tc.err, 3), "Test case %d", i)
+	}
+}
+
+func TestStatusRetryPolicyOverrides(t *testing.T) {
+	p := &StatusRetryPolicy{MaxRetries: map[string]uint32{
+		ReasonTooManyRequests: 0,
+		ReasonShutdown:        10,
+		ReasonBadDeviceToken:  1,
+	}}
+	assert.Equal(t, uint32(0), p.RetryLimit(&Response{StatusCode: 429, RejectionReason: ReasonTooManyRequests}, nil, 3))
+	assert.Equal(t, uint32(10), p.RetryLimit(&Response{StatusCode: 503, RejectionReason: ReasonShutdown}, nil, 3))
+	assert.Equal(t, uint32(3), p.RetryLimit(&Response{StatusCode: 503, RejectionReason: ReasonServiceUnavailable}, nil, 3))
+	assert.Equal(t, uint32(1), p.RetryLimit(&Response{StatusCode: 400, RejectionReason: ReasonBadDeviceToken}, nil, 3))
+}
+
+func TestProcCfgRetryLimit(t *testing.T) {
+	shutdown := &Response{StatusCode: 503, RejectionReason: ReasonShutdown}
+	cfg := ProcCfg{}
+	assert.Equal(t, uint32(0), cfg.retryLimit(shutdown, nil))
+	assert.True(t, cfg.isRetriable(shutdown, nil))
+	cfg.MaxRetries = 2
+	assert.Equal(t, uint32(2), cfg.retryLimit(shutdown, nil))
+	cfg.RetryEval = func(*Response, error) bool { return false }
+	assert.Equal(t, uint32(0), cfg.retryLimit(shutdown, nil))
+	assert.False(t, cfg.isRetriable(shutdown, nil))
+	cfg.RetryPolicy = &StatusRetryPolicy{MaxRetries: map[string]uint32{ReasonShutdown: 5}}
+	assert.Equal(t, uint32(5), cfg.retryLimit(shutdown, nil))
+}
+
+// timeoutError mimics timeouts reported by net and net/http packages.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
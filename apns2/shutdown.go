@@ -0,0 +1,328 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShutdownReport accounts for the requests that were still being processed
+// when client shutdown was initiated.
+type ShutdownReport struct {
+
+	// Completed is the number of requests whose outcome was determined
+	// and reported back during shutdown.
+	Completed uint64
+
+	// Canceled is the number of requests that were abandoned by hard stop
+	// after they may have reached APN service, either because their
+	// roundtrip was in progress or because they were awaiting a retry.
+	Canceled uint64
+
+	// Unattempted is the number of requests that were abandoned by hard stop
+	// before they were ever sent to APN service, including any requests
+	// left in client's Queue.
+	Unattempted uint64
+
+	// Unknown is the number of requests that the processing pipeline failed
+	// to account for within a grace period after hard stop. Such requests
+	// are neither in Abandoned nor counted as Canceled or Unattempted.
+	// It is always 0 unless there is a defect in request accounting.
+	Unknown uint64
+
+	// Hard is true if shutdown had to be escalated to hard stop.
+	Hard bool
+
+	// Abandoned holds the requests that were abandoned by hard stop,
+	// so that they can be persisted and resubmitted later.
+	Abandoned []*AbandonedRequest
+}
+
+// AbandonedRequest is a request that was abandoned by hard stop before
+// its outcome could be reported back. Callbacks of abandoned requests
+// are never invoked.
+type AbandonedRequest struct {
+	*Request
+
+	// OutcomeUnknown is true if the request's roundtrip was in progress
+	// when it was abandoned. Such a request may or may not have been
+	// delivered to APN service, and resubmitting it may result
+	// in a duplicate notification.
+	OutcomeUnknown bool
+}
+
+// killGracePeriod bounds the time StopContext waits for the processing
+// pipeline to account for all abandoned requests after hard stop.
+var killGracePeriod = time.Second
+
+// shutdownTracker keeps track of requests that are in the processing
+// pipeline so that shutdown can tell when the pipeline is idle.
+type shutdownTracker struct {
+	// number of requests dispatched for processing whose outcome
+	// has not yet been reported
+	pending int64
+
+	// abandoned request counts
+	canceled    uint64
+	unattempted uint64
+
+	// number of requests unaccounted for after hard stop
+	unknown uint64
+
+	// requests abandoned by hard stop
+	mu        sync.Mutex
+	abandoned []*AbandonedRequest
+
+	// client's Callback to be closed once pending count drops to zero
+	callback chan<- *Result
+
+	// signaled when pending count drops to zero or when submitter
+	// has drained client's Queue
+	idle chan struct{}
+
+	// closed by submitter once it has drained client's Queue
+	drained chan struct{}
+}
+
+func (t *shutdownTracker) init() {
+	t.idle = make(chan struct{}, 1)
+	t.drained = make(chan struct{})
+}
+
+func (t *shutdownTracker) poke() {
+	select {
+	case t.idle <- struct{}{}:
+	default:
+	}
+}
+
+// dispatched must be called before req is handed to streamers. It returns
+// true if req was not already accounted for as being in the pipeline.
+func (t *shutdownTracker) dispatched(req *Request) bool {
+	if req.dispatched {
+		return false
+	}
+	req.dispatched = true
+	atomic.AddInt64(&t.pending, 1)
+	return true
+}
+
+// resolved must be called when req leaves the processing pipeline.
+func (t *shutdownTracker) resolved(req *Request) {
+	if req.dispatched {
+		req.dispatched = false
+		if atomic.AddInt64(&t.pending, -1) == 0 {
+			t.poke()
+			t.closeCallback()
+		}
+	}
+}
+
+// closeWhenIdle arranges for cb to be closed once no more results can be
+// delivered to it. This is right away unless there are requests still
+// in the pipeline, in which case cb is closed when the last of them
+// is resolved.
+func (t *shutdownTracker) closeWhenIdle(cb chan<- *Result) {
+	if cb == nil || cb == NoCallback {
+		return
+	}
+	t.mu.Lock()
+	t.callback = cb
+	t.mu.Unlock()
+	if atomic.LoadInt64(&t.pending) <= 0 {
+		t.closeCallback()
+	}
+}
+
+func (t *shutdownTracker) closeCallback() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.callback != nil {
+		close(t.callback)
+		t.callback = nil
+	}
+}
+
+// drain must be called once client's Queue is no longer read from.
+func (t *shutdownTracker) drain() {
+	close(t.drained)
+	t.poke()
+}
+
+func (t *shutdownTracker) isIdle() bool {
+	select {
+	case <-t.drained:
+		return atomic.LoadInt64(&t.pending) <= 0
+	default:
+		return false
+	}
+}
+
+// discard accounts for a request that is abandoned by hard stop.
+// InFlight indicates that the request may have reached APN service.
+func (c *Client) discard(req *Request, inFlight bool) {
+	if inFlight || req.attemptCnt > 0 {
+		atomic.AddUint64(&c.shutdown.canceled, 1)
+	} else {
+		atomic.AddUint64(&c.shutdown.unattempted, 1)
+	}
+	c.shutdown.resolved(req)
+	// Resubmitted request starts afresh.
+	req.attemptCnt = 0
+	req.retryDelay = 0
+	c.shutdown.mu.Lock()
+	c.shutdown.abandoned = append(c.shutdown.abandoned, &AbandonedRequest{Request: req, OutcomeUnknown: inFlight})
+	c.shutdown.mu.Unlock()
+}
+
+// takeAbandoned returns all requests abandoned so far and forgets about them.
+func (t *shutdownTracker) takeAbandoned() []*AbandonedRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := t.abandoned
+	t.abandoned = nil
+	return res
+}
+
+// isKilled returns true if hard stop has been initiated.
+func (c *Client) isKilled() bool {
+	return c.runCtx.Err() != nil
+}
+
+// StopContext performs graceful shutdown of the Client bounded by ctx.
+// The client stops accepting new pushes, submits any requests remaining
+// in its Queue and waits for the outcome of all requests that are being
+// processed, including any pending retries. If ctx is done before that,
+// shutdown is escalated to hard stop as per Kill, and all outstanding
+// HTTP/2 requests are canceled. A paused client is resumed so that
+// remaining requests can be processed.
+//
+// The returned report accounts for requests that were being processed
+// during shutdown. Requests abandoned by hard stop are never reported back
+// to their callbacks, neither client's nor request's own. Instead they are
+// handed back in the report.
+//
+// Client's Callback, if set, is closed once no more results can be written
+// to it, so that consumers ranging over it terminate. Normally this happens
+// before StopContext returns. If hard stop leaves requests whose outcome is
+// still being determined, Callback is closed as soon as the last of them
+// has been reported.
+func (c *Client) StopContext(ctx context.Context) (*ShutdownReport, error) {
+	c.mu.Lock()
+	if c.state >= stateStopping {
+		c.mu.Unlock()
+		return nil, ErrClientAlreadyClosed
+	}
+	c.state = stateStopping
+	c.log.info("Stopping.")
+	ev := ClientEvent{Event: c.newEvent()}
+	c.notify(func(o Observer) { o.ClientStopping(ev) })
+	completed := atomic.LoadUint64(&c.stats.completed)
+	if c.pause.set(false) {
+		// Remaining requests cannot be processed otherwise.
+		c.log.info("Resumed.")
+	}
+	close(c.sctl) // submitter drains Queue and stops taking new requests
+	c.mu.Unlock()
+	var ctxDone <-chan struct{}
+	if ctx != NoContext {
+		ctxDone = ctx.Done()
+	}
+	hard := false
+	// Block until all processing is complete or we are signaled to terminate.
+	for !hard && !c.shutdown.isIdle() {
+		select {
+		case <-c.shutdown.idle:
+		case <-ctxDone:
+			hard = true
+		case <-c.ctl:
+			hard = true
+		}
+	}
+	if !hard {
+		c.mu.Lock()
+		c.stopSubmitterLocked()
+		c.mu.Unlock()
+		c.wg.Wait()
+		close(c.out)
+		close(c.gov.stop)
+		select {
+		case <-c.cdone:
+		case <-ctxDone:
+			hard = true
+		case <-c.ctl:
+			hard = true
+		}
+	}
+	if hard {
+		c.log.info("Escalating to hard stop.")
+		c.mu.Lock()
+		if c.state < stateTerminating {
+			c.state = stateTerminating
+		}
+		c.killLocked()
+		c.mu.Unlock()
+		c.awaitAbandoned()
+	}
+	res := &ShutdownReport{
+		Completed:   atomic.LoadUint64(&c.stats.completed) - completed,
+		Canceled:    atomic.LoadUint64(&c.shutdown.canceled),
+		Unattempted: atomic.LoadUint64(&c.shutdown.unattempted),
+		Unknown:     atomic.LoadUint64(&c.shutdown.unknown),
+		Hard:        hard,
+		Abandoned:   c.shutdown.takeAbandoned(),
+	}
+	c.shutdown.closeWhenIdle(c.Callback)
+	c.log.info("Stopped.")
+	return res, nil
+}
+
+// KillAndCollect performs hard shutdown of the Client as per Kill and returns
+// all requests that were abandoned as a result. This includes requests left
+// in client's Queue, requests waiting to be sent or awaiting a retry, and
+// requests whose roundtrips were canceled, which are flagged as having
+// unknown outcome. KillAndCollect waits briefly for the processing pipeline
+// to account for all abandoned requests. Callbacks of abandoned requests
+// are never invoked.
+func (c *Client) KillAndCollect() ([]*AbandonedRequest, error) {
+	if err := c.Kill(); err != nil {
+		return nil, err
+	}
+	c.awaitAbandoned()
+	return c.shutdown.takeAbandoned(), nil
+}
+
+// awaitAbandoned waits for the processing pipeline to account for requests
+// abandoned by hard stop, and then accounts for requests left in Queue.
+func (c *Client) awaitAbandoned() {
+	tmr := time.NewTimer(killGracePeriod)
+	defer tmr.Stop()
+	for timedOut := false; !timedOut && atomic.LoadInt64(&c.shutdown.pending) > 0; {
+		select {
+		case <-c.shutdown.idle:
+		case <-tmr.C:
+			// All abandoned requests are expected to be accounted for
+			// by now. Any that aren't have been lost in the pipeline.
+			n := atomic.LoadInt64(&c.shutdown.pending)
+			c.log.warn("%d requests were not accounted for after hard stop.", n)
+			atomic.StoreUint64(&c.shutdown.unknown, uint64(n))
+			timedOut = true
+		}
+	}
+	// Submitter may still be taking a request from Queue.
+	c.wg.Wait()
+	for {
+		select {
+		case req, ok := <-c.Queue:
+			if !ok {
+				return
+			}
+			c.discard(req, false)
+		default:
+			return
+		}
+	}
+}
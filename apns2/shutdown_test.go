@@ -0,0 +1,251 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/baobabus/go-apnsmock/apns2mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_StopContext_Drain(t *testing.T) {
+	s := mustNewMockServerWithCfg(t, apns2mock.CommsCfg{
+		MaxConcurrentStreams: 500,
+		MaxConns:             1000,
+		ResponseTime:         100 * time.Millisecond,
+	})
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	cb := make(chan *Result, 10)
+	c.Callback = cb
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := c.Push(testNotif_Good, DefaultSigner, NoContext, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rep, err := c.StopContext(ctx)
+	assert.Nil(t, err)
+	// Some pushes may have completed before shutdown.
+	assert.True(t, rep.Completed >= 1 && rep.Completed <= 5)
+	assert.Equal(t, &ShutdownReport{Completed: rep.Completed}, rep)
+	cnt := 0
+	for res := range cb {
+		assert.True(t, res.IsAccepted())
+		cnt++
+	}
+	assert.Equal(t, 5, cnt)
+	assert.Equal(t, ErrClientNotRunning, c.Push(testNotif_Good, DefaultSigner, NoContext, nil))
+	_, err = c.StopContext(ctx)
+	assert.Equal(t, ErrClientAlreadyClosed, err)
+}
+
+func TestClient_StopContext_Deadline(t *testing.T) {
+	s := mustNewMockServerWithCfg(t, apns2mock.CommsCfg{
+		MaxConcurrentStreams: 500,
+		MaxConns:             1000,
+		ResponseTime:         time.Second,
+	})
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	// With streams capped at one, one request is sent and the other one waits
+	// for an available stream. Both can be under way otherwise.
+	for i := 0; i < 2; i++ {
+		if err := c.Push(testNotif_Good, DefaultSigner, NoContext, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Let the roundtrips get under way.
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	rep, err := c.StopContext(ctx)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.True(t, rep.Hard)
+	assert.Equal(t, uint64(0), rep.Completed)
+	assert.True(t, rep.Canceled >= 1)
+	assert.Equal(t, uint64(2), rep.Canceled+rep.Unattempted)
+	assert.Equal(t, 2, len(rep.Abandoned))
+	assert.Equal(t, ErrClientAlreadyClosed, c.Kill())
+}
+
+func TestClient_KillAndCollect(t *testing.T) {
+	s := mustNewMockServerWithCfg(t, apns2mock.CommsCfg{
+		MaxConcurrentStreams: 500,
+		MaxConns:             1000,
+		ResponseTime:         time.Second,
+	})
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Push(testNotif_Good, DefaultSigner, NoContext, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Let the roundtrips get under way.
+	time.Sleep(50 * time.Millisecond)
+	reqs, err := c.KillAndCollect()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(reqs))
+	unknown := 0
+	for _, r := range reqs {
+		assert.Equal(t, testNotif_Good, r.Notification)
+		assert.Equal(t, 0, r.attemptCnt)
+		if r.OutcomeUnknown {
+			unknown++
+		}
+	}
+	assert.True(t, unknown >= 1)
+	_, err = c.KillAndCollect()
+	assert.Equal(t, ErrClientAlreadyClosed, err)
+}
+
+func TestStreamer_QuitAbortsRoundTrips(t *testing.T) {
+	s := mustNewMockServerWithCfg(t, apns2mock.CommsCfg{
+		MaxConcurrentStreams: 500,
+		MaxConns:             1000,
+		ResponseTime:         time.Second,
+	})
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Kill()
+	cb := make(chan *Result, 1)
+	if err := c.Push(testNotif_Good, DefaultSigner, NoContext, cb); err != nil {
+		t.Fatal(err)
+	}
+	// Let the roundtrip get under way.
+	time.Sleep(50 * time.Millisecond)
+	c.gov.mu.Lock()
+	for w, _ := range c.gov.streamers {
+		w.quit <- struct{}{}
+	}
+	c.gov.mu.Unlock()
+	select {
+	case res := <-cb:
+		assert.Equal(t, ErrRoundTripAborted, res.Err)
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("Roundtrip should have been aborted")
+	}
+}
+
+func TestShutdownTracker_CloseWhenIdle(t *testing.T) {
+	var tr shutdownTracker
+	tr.init()
+	cb := make(chan *Result, 1)
+	tr.closeWhenIdle(cb)
+	_, ok := <-cb
+	assert.False(t, ok)
+	// Straggler keeps Callback open until it is resolved.
+	cb = make(chan *Result, 1)
+	req := &Request{}
+	assert.True(t, tr.dispatched(req))
+	tr.closeWhenIdle(cb)
+	cb <- &Result{}
+	tr.resolved(req)
+	_, ok = <-cb
+	assert.True(t, ok)
+	_, ok = <-cb
+	assert.False(t, ok)
+	tr.closeWhenIdle(NoCallback)
+}
+
+func TestClient_KillAndCollect_Retries(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	c.CommsCfg.DialTimeout = time.Second
+	c.CommsCfg.RequestTimeout = 5 * time.Second
+	c.ProcCfg.RetryPolicy = &StatusRetryPolicy{MaxRetries: map[string]uint32{ReasonBadDeviceToken: 1}}
+	c.ProcCfg.MinRetryBackOff = time.Hour
+	c.ProcCfg.MaxRetryBackOff = time.Hour
+	if err := c.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	// More retries than retry scheduler buffers, so that some may still
+	// be buffered when the client is killed.
+	n := 150
+	for i := 0; i < n; i++ {
+		if err := c.Push(testNotif_BadDevice, DefaultSigner, NoContext, NoCallback); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 200 && c.Stats().Retried < uint64(n); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, uint64(n), c.Stats().Retried)
+	start := time.Now()
+	reqs, err := c.KillAndCollect()
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < killGracePeriod)
+	assert.Equal(t, n, len(reqs))
+	for _, r := range reqs {
+		assert.False(t, r.OutcomeUnknown)
+	}
+}
+
+func TestGovernor_RetriesAbandoned(t *testing.T) {
+	g := newTestGovernor(ProcCfg{MinRetryBackOff: time.Hour, MaxRetryBackOff: time.Hour}, 0)
+	g.c.shutdown.init()
+	g.retry = make(chan *parkedRequest, 100)
+	ctl := make(chan struct{})
+	g.ctl = ctl
+	// Retries are buffered, as scheduler is not yet running.
+	for i := 0; i < 10; i++ {
+		req := &Request{Notification: &Notification{Header: &Header{}}, Context: NoContext, attemptCnt: 1}
+		g.c.shutdown.dispatched(req)
+		assert.True(t, g.requestRetry(&parkedRequest{req: req}))
+	}
+	close(ctl)
+	done := make(chan struct{})
+	go func() {
+		g.runRetryScheduler()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for retry scheduler")
+	}
+	assert.False(t, g.requestRetry(&parkedRequest{req: &Request{}}))
+	assert.Equal(t, 10, len(g.c.shutdown.takeAbandoned()))
+	assert.Equal(t, int64(0), g.c.shutdown.pending)
+}
+
+func TestClient_AwaitAbandoned_Unknown(t *testing.T) {
+	defer func(d time.Duration) { killGracePeriod = d }(killGracePeriod)
+	killGracePeriod = 10 * time.Millisecond
+	c := &Client{}
+	c.shutdown.init()
+	c.shutdown.dispatched(&Request{})
+	c.awaitAbandoned()
+	// Lost request is neither canceled nor abandoned.
+	assert.Equal(t, uint64(1), c.shutdown.unknown)
+	assert.Equal(t, uint64(0), c.shutdown.canceled)
+	assert.Empty(t, c.shutdown.takeAbandoned())
+}
@@ -0,0 +1,306 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time snapshot of Client's processing pipeline
+// and connection metrics. All counts are cumulative since the client
+// was started, unless noted otherwise.
+type Stats struct {
+
+	// Queued is the number of requests currently buffered in client's Queue.
+	Queued int
+
+	// Submitted is the number of requests that have been dispatched
+	// to streamers, including any retries.
+	Submitted uint64
+
+	// InFlight is the number of requests currently holding an HTTP/2 stream.
+	InFlight uint64
+
+	// Parked is the number of requests currently awaiting a retry.
+	Parked uint64
+
+	// Retried is the number of retry attempts that have been scheduled.
+	Retried uint64
+
+	// Completed is the number of requests for which the final outcome
+	// is known.
+	Completed uint64
+
+	// Accepted is the number of notifications accepted by APN service.
+	Accepted uint64
+
+	// Failed is the number of requests that failed without a response
+	// from APN service.
+	Failed uint64
+
+	// ByStatus holds the number of APN service responses by status code.
+	ByStatus map[int]uint64
+
+	// ByReason holds the number of rejections by rejection reason.
+	ByReason map[string]uint64
+
+	// Results holds the number of completed requests by topic, status code
+	// and rejection reason. Requests that failed without a response from
+	// APN service are counted with zero status code.
+	Results map[ResultKey]uint64
+
+	// Latency is the distribution of round trip times of requests that
+	// received a response from APN service.
+	Latency Histogram
+
+	// Streamers holds metrics of all active streamers.
+	Streamers []StreamerStats
+
+	// Launching is the number of streamers currently being launched.
+	Launching int
+
+	// WindingDown is the number of streamers currently being wound down.
+	WindingDown int
+
+	// DialFailures is the number of failed streamer launches.
+	DialFailures uint64
+
+	// GoAways is the number of streamer connections that were found
+	// to be going away.
+	GoAways uint64
+
+	// BackOffUntil is the end of the current dial back-off window.
+	// It is in the past if no back-off is in effect.
+	BackOffUntil time.Time
+
+	// ScaleUps is the number of scale-up events.
+	ScaleUps uint64
+
+	// WindDowns is the number of wind-down events.
+	WindDowns uint64
+
+	// DroppedEvents is the number of events that could not be delivered
+	// to client's Observer because it was falling behind.
+	DroppedEvents uint64
+	// Paused is true if client's processing is paused.
+	Paused bool
+
+	// OutageSince is the start of the ongoing period during which there
+	// have been no connections to APN service available. It is zero if there
+	// is at least one active streamer.
+	OutageSince time.Time
+
+	// FailingFast is true if pushes are failing fast with ErrNoConnections
+	// due to the outage having lasted longer than ProcCfg.MaxOutage.
+	FailingFast bool
+}
+
+// ResultKey identifies a group of push results.
+type ResultKey struct {
+	Topic      string
+	StatusCode int
+	Reason     string
+}
+
+// LatencyBuckets are the upper bounds of latency histogram buckets.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Histogram is a distribution of observed durations.
+type Histogram struct {
+
+	// Bounds are the inclusive upper bounds of the histogram buckets.
+	Bounds []time.Duration
+
+	// Counts holds the number of observations in each bucket. It has one more
+	// element than Bounds with the last one counting all observations
+	// exceeding the highest bound. Counts are not cumulative.
+	Counts []uint64
+
+	// Count is the total number of observations.
+	Count uint64
+
+	// Sum is the sum of all observed durations.
+	Sum time.Duration
+}
+
+// StreamerStats holds metrics of a single streamer and its HTTP/2 connection.
+type StreamerStats struct {
+
+	// Id identifies the streamer in log entries.
+	Id string
+
+	// InFlight is the number of currently reserved HTTP/2 streams.
+	InFlight uint32
+
+	// MaxConcurrentStreams is the MAX_CONCURRENT_STREAMS setting negotiated
+	// with APN server. It is 0 if the setting is not known, which is the case
+	// when HTTP/2 incursion is not allowed.
+	MaxConcurrentStreams uint32
+
+	// StreamCap is the number of concurrent streams the streamer is currently
+	// allowed to use.
+	StreamCap uint32
+}
+
+// Stats returns a snapshot of client's metrics. It is safe to call Stats
+// concurrently with any other client's activity.
+func (c *Client) Stats() *Stats {
+	res := &Stats{
+		Queued:      len(c.Queue),
+		Paused:      c.pause.isSet(),
+		FailingFast: c.outage.isSet(),
+	}
+	c.stats.snapshot(res)
+	c.mu.RLock()
+	gov := c.gov
+	events := c.events
+	c.mu.RUnlock()
+	if events != nil {
+		res.DroppedEvents = atomic.LoadUint64(&events.dropped)
+	}
+	if gov != nil {
+		gov.snapshot(res)
+	}
+	return res
+}
+
+// statsCollector accumulates client-wide counters that, unlike counters used
+// for scaling decisions, are never reset.
+type statsCollector struct {
+	submitted uint64
+	retried   uint64
+	completed uint64
+	accepted  uint64
+	failed    uint64
+
+	mu       sync.Mutex
+	byStatus map[int]uint64
+	byReason map[string]uint64
+	results  map[ResultKey]uint64
+	latency  Histogram
+}
+
+func (s *statsCollector) addSubmitted() {
+	atomic.AddUint64(&s.submitted, 1)
+}
+
+func (s *statsCollector) addRetried() {
+	atomic.AddUint64(&s.retried, 1)
+}
+
+func (s *statsCollector) addResult(topic string, resp *Response, err error) {
+	atomic.AddUint64(&s.completed, 1)
+	key := ResultKey{Topic: topic}
+	if resp == nil {
+		if err != nil {
+			atomic.AddUint64(&s.failed, 1)
+		}
+	} else if err == nil && resp.IsAccepted() {
+		atomic.AddUint64(&s.accepted, 1)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.results == nil {
+		s.byStatus = make(map[int]uint64)
+		s.byReason = make(map[string]uint64)
+		s.results = make(map[ResultKey]uint64)
+	}
+	if resp != nil {
+		key.StatusCode = resp.StatusCode
+		key.Reason = resp.RejectionReason
+		s.byStatus[resp.StatusCode]++
+		if resp.RejectionReason != "" {
+			s.byReason[resp.RejectionReason]++
+		}
+	}
+	s.results[key]++
+}
+
+func (s *statsCollector) addLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latency.Counts == nil {
+		s.latency.Bounds = LatencyBuckets
+		s.latency.Counts = make([]uint64, len(LatencyBuckets)+1)
+	}
+	i := sort.Search(len(s.latency.Bounds), func(i int) bool { return d <= s.latency.Bounds[i] })
+	s.latency.Counts[i]++
+	s.latency.Count++
+	s.latency.Sum += d
+}
+
+func (s *statsCollector) snapshot(res *Stats) {
+	res.Submitted = atomic.LoadUint64(&s.submitted)
+	res.Retried = atomic.LoadUint64(&s.retried)
+	res.Completed = atomic.LoadUint64(&s.completed)
+	res.Accepted = atomic.LoadUint64(&s.accepted)
+	res.Failed = atomic.LoadUint64(&s.failed)
+	res.ByStatus = make(map[int]uint64)
+	res.ByReason = make(map[string]uint64)
+	res.Results = make(map[ResultKey]uint64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.byStatus {
+		res.ByStatus[k] = v
+	}
+	for k, v := range s.byReason {
+		res.ByReason[k] = v
+	}
+	for k, v := range s.results {
+		res.Results[k] = v
+	}
+	res.Latency = s.latency
+	if s.latency.Counts != nil {
+		res.Latency.Counts = append([]uint64(nil), s.latency.Counts...)
+	} else {
+		res.Latency.Bounds = LatencyBuckets
+		res.Latency.Counts = make([]uint64, len(LatencyBuckets)+1)
+	}
+}
+
+func (g *governor) snapshot(res *Stats) {
+	res.Parked = uint64(atomic.LoadInt64(&g.parked))
+	res.DialFailures = atomic.LoadUint64(&g.dialFailures)
+	res.GoAways = atomic.LoadUint64(&g.goAwayCnt)
+	res.ScaleUps = atomic.LoadUint64(&g.scaleUps)
+	res.WindDowns = atomic.LoadUint64(&g.windDowns)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	res.Launching = len(g.launchers)
+	res.WindingDown = len(g.retirees)
+	res.BackOffUntil = g.backOffTracker.blackoutEnd()
+	res.OutageSince = g.outageStart
+	ws := make([]*streamer, 0, len(g.streamers)+len(g.retirees))
+	for w, _ := range g.streamers {
+		ws = append(ws, w)
+	}
+	for w, _ := range g.retirees {
+		ws = append(ws, w)
+	}
+	res.Streamers = make([]StreamerStats, 0, len(g.streamers))
+	for _, w := range ws {
+		st := StreamerStats{Id: w.id}
+		if w.httpClient != nil {
+			st.InFlight, st.MaxConcurrentStreams, st.StreamCap = w.httpClient.streamStats()
+		}
+		res.InFlight += uint64(st.InFlight)
+		if _, ok := g.streamers[w]; ok {
+			res.Streamers = append(res.Streamers, st)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsCollector(t *testing.T) {
+	var s statsCollector
+	s.addSubmitted()
+	s.addSubmitted()
+	s.addSubmitted()
+	s.addRetried()
+	s.addResult("a", &Response{StatusCode: 200}, nil)
+	s.addResult("b", &Response{StatusCode: 410, RejectionReason: ReasonUnregistered}, nil)
+	s.addResult("a", nil, errors.New(""))
+	s.addLatency(time.Millisecond)
+	s.addLatency(7 * time.Millisecond)
+	s.addLatency(time.Minute)
+	var res Stats
+	s.snapshot(&res)
+	assert.Equal(t, uint64(3), res.Submitted)
+	assert.Equal(t, uint64(1), res.Retried)
+	assert.Equal(t, uint64(3), res.Completed)
+	assert.Equal(t, uint64(1), res.Accepted)
+	assert.Equal(t, uint64(1), res.Failed)
+	assert.Equal(t, map[int]uint64{200: 1, 410: 1}, res.ByStatus)
+	assert.Equal(t, map[string]uint64{ReasonUnregistered: 1}, res.ByReason)
+	assert.Equal(t, map[ResultKey]uint64{
+		ResultKey{"a", 200, ""}:                 1,
+		ResultKey{"b", 410, ReasonUnregistered}: 1,
+		ResultKey{"a", 0, ""}:                   1,
+	}, res.Results)
+	assert.Equal(t, uint64(3), res.Latency.Count)
+	assert.Equal(t, time.Minute+8*time.Millisecond, res.Latency.Sum)
+	assert.Equal(t, len(LatencyBuckets)+1, len(res.Latency.Counts))
+	assert.Equal(t, uint64(1), res.Latency.Counts[0])
+	assert.Equal(t, uint64(1), res.Latency.Counts[1])
+	assert.Equal(t, uint64(1), res.Latency.Counts[len(LatencyBuckets)])
+	// Snapshot must not be affected by subsequent updates
+	s.addResult("a", &Response{StatusCode: 200}, nil)
+	s.addLatency(time.Millisecond)
+	assert.Equal(t, uint64(1), res.ByStatus[200])
+	assert.Equal(t, uint64(1), res.Latency.Counts[0])
+}
+
+func TestClient_Stats(t *testing.T) {
+	s := mustNewMockServer(t)
+	defer s.Close()
+	c := mustNewClient_Signer_Good(t, s)
+	st := c.Stats()
+	assert.Equal(t, uint64(0), st.Submitted)
+	assert.Empty(t, st.Streamers)
+	err := c.Start(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	c.PushSync(NoContext, testNotif_Good, DefaultSigner)
+	c.PushSync(NoContext, testNotif_BadDevice, DefaultSigner)
+	// Stream is released only after the result has been delivered.
+	st = c.Stats()
+	for i := 0; i < 100 && st.InFlight > 0; i++ {
+		time.Sleep(time.Millisecond)
+		st = c.Stats()
+	}
+	assert.Equal(t, uint64(2), st.Submitted)
+	assert.Equal(t, uint64(2), st.Completed)
+	assert.Equal(t, uint64(1), st.Accepted)
+	assert.Equal(t, uint64(0), st.InFlight)
+	assert.Equal(t, map[int]uint64{200: 1, 400: 1}, st.ByStatus)
+	assert.Equal(t, map[string]uint64{ReasonBadDeviceToken: 1}, st.ByReason)
+	assert.Equal(t, 1, len(st.Streamers))
+	assert.Equal(t, uint64(2), st.Latency.Count)
+	assert.Equal(t, uint64(0), st.DialFailures)
+}
@@ -3,14 +3,17 @@
 package apns2
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/baobabus/go-apns/syncx"
+	"golang.org/x/net/http2"
 )
 
 // Each streamer "owns" a single HTTPClient on top of an HTTP/2 transport.
@@ -20,11 +23,25 @@ type streamer struct {
 	id   string
 	c    *Client
 	gov  *governor
+	log  *logger
 	in   <-chan *Request
-	out  chan<- *Result
 	ctl  chan struct{}
 	done chan<- *streamer
 
+	// retire is closed by the governor when the streamer is being wound down
+	retire chan struct{}
+
+	// quit is signaled by roundtrips that find the connection unusable
+	quit chan struct{}
+
+	// goAway is signaled by roundtrips that find the connection going away
+	goAway chan *http2.GoAwayError
+
+	// ctx is canceled when streamer quits or is terminated, which aborts
+	// all of its pending roundtrips
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	warmStart bool
 
 	startOnce sync.Once
@@ -40,24 +57,35 @@ type streamer struct {
 	// wait group for spawned HTTP/2 roundrips
 	wg sync.WaitGroup
 
+	// time at which the streamer was launched
+	started time.Time
+
 	didQuit  bool
 	inClosed bool
+
+	// set if the streamer stopped taking requests because its connection
+	// was going away, along with the details of GOAWAY frame, if known
+	wentAway  bool
+	goAwayErr *http2.GoAwayError
 }
 
 func (s *streamer) start(wg *sync.WaitGroup) error {
 	s.startOnce.Do(func() {
-		logInfo(s.id, "Starting.")
-		s.httpClient, s.startErr = NewHTTPClient(s.c.Gateway, s.c.CommsCfg, s.c.Certificate, s.c.RootCA)
+		s.log.info("Starting.")
+		s.ctx, s.cancel = context.WithCancel(s.c.runCtx)
+		cfg := s.gov.config()
+		s.httpClient, s.startErr = NewHTTPClient(s.c.Gateway, cfg.comms, s.c.Certificate, s.c.RootCA)
 		if s.startErr != nil {
 			return
 		}
 		var pollInt time.Duration
-		if s.gov.cfg.AllowHTTP2Incursion && !s.gov.cfg.UsePreciseHTTP2Metrics {
-			pollInt = s.gov.cfg.HTTP2MetricsRefreshPeriod
+		if cfg.proc.AllowHTTP2Incursion && !cfg.proc.UsePreciseHTTP2Metrics {
+			pollInt = cfg.proc.HTTP2MetricsRefreshPeriod
 		}
-		s.httpClient.precise = s.gov.cfg.AllowHTTP2Incursion && s.gov.cfg.UsePreciseHTTP2Metrics
+		s.httpClient.log = s.log
+		s.httpClient.precise = cfg.proc.AllowHTTP2Incursion && cfg.proc.UsePreciseHTTP2Metrics
 		s.httpClient.pollInt = pollInt
-		s.httpClient.cfgCap = s.c.CommsCfg.MaxConcurrentStreams
+		s.httpClient.cfgCap = cfg.comms.MaxConcurrentStreams
 		if s.warmStart {
 			// This can also be accomplished by sending a malformed http.Request.
 			// No reflection is required, but it's still a kludge and results
@@ -76,33 +104,87 @@ func (s *streamer) start(wg *sync.WaitGroup) error {
 }
 
 func (s *streamer) run(wg *sync.WaitGroup) {
-	logInfo(s.id, "Running.")
+	s.log.info("Running.")
+	cfg := s.gov.config()
+	var ageChan <-chan time.Time
+	if age := cfg.comms.MaxConnectionAge; age > 0 {
+		tmr := time.NewTimer(jittered(age, 0, cfg.comms.MaxConnectionAgeJitter))
+		defer tmr.Stop()
+		ageChan = tmr.C
+	}
+	// Connection state can only be polled if HTTP/2 incursion is allowed.
+	var pollChan <-chan time.Time
+	if cfg.proc.AllowHTTP2Incursion && cfg.proc.PollInterval > 0 {
+		tkr := time.NewTicker(cfg.proc.PollInterval)
+		defer tkr.Stop()
+		pollChan = tkr.C
+	}
 	for done := false; !done; {
+		in := s.in
+		paused, change := s.c.pause.get()
+		if paused {
+			in = nil
+		}
 		select {
-		case req, ok := <-s.in:
+		case <-change:
+			// Re-evaluate paused state.
+		case req, ok := <-in:
 			if !ok {
 				// soft shutdown - wait for pending roundtrips to complete
-				logInfo(s.id, "Stopping.")
-				// TODO Switch from WaitGroup to channel signal
-				s.wg.Wait()
+				s.log.info("Stopping.")
+				s.drain()
 				done = true
 				s.inClosed = true
 				break
 			}
 			s.exec(req)
-		case _, ok := <-s.ctl:
-			if ok {
-				// unusable connection
-				s.didQuit = true
-				logInfo(s.id, "Quitting.")
-			} else {
-				// hard shutdown - do not wait for pending roundtrips to complete
-				logInfo(s.id, "Terminating.")
+		case <-s.retire:
+			// wind-down - stop taking requests and let pending roundtrips complete
+			s.log.info("Winding down.")
+			s.drain()
+			done = true
+		case <-ageChan:
+			// Keep taking requests until the replacement is running.
+			s.log.info("Connection reached maximum age.")
+			ageChan = nil
+			select {
+			case s.gov.aged <- s:
+			case <-s.ctl:
+			}
+		case <-pollChan:
+			if gone, ga := s.httpClient.goneAway(); gone {
+				s.goAwayErr = ga
+				s.goingAway()
+				done = true
 			}
-			// TODO Cancel pending roundtrips' contexts.
+		case ga := <-s.goAway:
+			s.goAwayErr = ga
+			s.goingAway()
+			done = true
+		case <-s.httpClient.pingFailed():
+			ev := ConnectionEvent{Event: s.c.newEvent(), StreamerId: s.id, Err: ErrPingTimeout}
+			s.c.notify(func(o Observer) { o.ConnectionUnusable(ev) })
+			s.didQuit = true
+			s.log.info("Quitting.")
+			// Pending roundtrips will fail on the lost connection anyway.
+			s.cancel()
+			done = true
+		case <-s.quit:
+			// unusable connection
+			s.didQuit = true
+			s.log.info("Quitting.")
+			// Pending roundtrips have no chance on this connection.
+			s.cancel()
+			done = true
+		case <-s.ctl:
+			// hard shutdown - do not wait for pending roundtrips to complete
+			s.log.info("Terminating.")
 			done = true
 		}
 	}
+	// Releases context resources. Only has effect on pending roundtrips
+	// if the streamer was terminated.
+	s.cancel()
 	// This will only have effect if all roundtrips are finished.
 	s.httpClient.Close()
 	// read from ctl prevents blocking on done if the governor
@@ -114,11 +196,43 @@ func (s *streamer) run(wg *sync.WaitGroup) {
 	if wg != nil {
 		wg.Done()
 	}
-	logInfo(s.id, "Stopped.")
+	s.log.info("Stopped.")
+}
+
+// goingAway stops the streamer from taking requests on a connection that
+// is going away. The governor is notified so that it can launch
+// a replacement while pending roundtrips are given a chance to complete.
+func (s *streamer) goingAway() {
+	s.wentAway = true
+	if s.goAwayErr != nil {
+		s.log.info("Connection going away: %v.", s.goAwayErr.ErrCode)
+	} else {
+		s.log.info("Connection going away.")
+	}
+	select {
+	case s.gov.goAways <- s:
+	case <-s.ctl:
+	}
+	s.drain()
+}
+
+// drain blocks until all pending roundtrips have completed or until
+// hard shutdown is signaled.
+func (s *streamer) drain() {
+	rtDone := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(rtDone)
+	}()
+	select {
+	case <-rtDone:
+	case <-s.ctl:
+		s.log.info("Terminating.")
+	}
 }
 
 func (s *streamer) exec(req *Request) {
-	logTrace(0, s.id, "Serving %v.", req)
+	s.log.trace(0, "Serving %v.", req)
 	if s.c.Certificate == nil && (req.Signer == NoSigner || !s.c.HasSigner() && !req.HasSigner()) {
 		s.callBack(req, nil, ErrMissingAuth)
 		return
@@ -137,65 +251,167 @@ func (s *streamer) exec(req *Request) {
 		s.callBack(req, nil, ErrCanceled)
 		return
 	}
-	var cancel func(done <-chan struct{}) error
+	if s.c.isKilled() {
+		s.c.discard(req, false)
+		return
+	}
+	// Request is prepared up front so that its size is known to the limiter.
+	httpReq, err := s.newHTTPRequest(req)
+	if err != nil {
+		s.callBack(req, nil, err)
+		return
+	}
+	if err := s.throttle(req, httpReq); err != nil {
+		if err == ErrPushInterrupted {
+			s.c.discard(req, false)
+		} else {
+			s.callBack(req, nil, err)
+		}
+		return
+	}
+	var ctxDone <-chan struct{}
 	if hasCtx {
-		// Waits for the user to cancel a request's context.
-		cancel = func(done <-chan struct{}) error {
-			ctx := req.Context
-			if ctx.Done() == nil {
-				return nil
-			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-done:
-				return nil
-			}
+		ctxDone = req.Context.Done()
+	}
+	// Waits for the user to cancel a request's context or for hard stop.
+	cancel := func(done <-chan struct{}) error {
+		select {
+		case <-ctxDone:
+			return req.Context.Err()
+		case <-s.c.runCtx.Done():
+			return ErrPushInterrupted
+		case <-done:
+			return nil
 		}
 	}
 	// 1. Acquire HTTP/2 stream
 	// This can block and is the primary source of back pressure.
 	st, err := s.httpClient.ReservedStream(cancel)
 	if err != nil {
-		s.callBack(req, nil, err)
+		if err == ErrPushInterrupted {
+			s.c.discard(req, false)
+		} else {
+			s.callBack(req, nil, err)
+		}
 		return
 	}
+	// Roundtrip is aborted if request's context is canceled, if streamer quits
+	// or on hard stop.
+	ctx, release := s.roundTripContext(req)
+	httpReq = httpReq.WithContext(ctx)
 	// 2. go submit()
 	s.wg.Add(1)
 	go func() {
 		defer st.Close()
 		defer s.wg.Done()
-		resp, err := s.submit(req)
-		if err != nil && uint32(req.attemptCnt) < s.gov.cfg.MaxRetries && s.isRetriable(resp, err) {
+		defer release()
+		resp, err := s.submit(httpReq)
+		ga := goAwayError(err)
+		if ga != nil {
+			// Signal is buffered, so there's no need to block.
+			select {
+			case s.goAway <- ga:
+			default:
+			}
+		}
+		if err != nil && s.ctx.Err() != nil {
+			// Outcome is unknown as the request may have reached APN service.
+			if s.c.isKilled() {
+				s.c.discard(req, true)
+			} else {
+				s.callBack(req, nil, ErrRoundTripAborted)
+			}
+			return
+		}
+		failed := err != nil || !resp.IsAccepted()
+		if failed && uint32(req.attemptCnt) < s.gov.config().proc.retryLimit(resp, err) {
 			req.attemptCnt++
 			// Retry is serviced in a timely manner, so no need to worry about blocking.
-			// There's just a potential issue with retry forwarder stopping reads
-			// due to a signal on its ctl channel with streamers still running.
-			// Forwarder's ctl channel shoulnd't be shared with governor.
-			s.gov.retry <- req
+			// Once retry scheduler is stopped by hard stop, the request is abandoned.
+			s.c.stats.addRetried()
+			if !s.gov.requestRetry(&parkedRequest{req: req, resp: resp, err: err}) {
+				s.c.discard(req, false)
+			}
 			return
 		}
 		s.callBack(req, resp, err)
-		if !s.isConnUsable(resp, err) {
-			// Each worker is given its own ctl channel, but we cannot close it here.
-			// Writing to it accomplishes the same thing. Just do not block.
-			var v struct{}
+		if ga == nil && !s.isConnUsable(resp, err) {
+			ev := ConnectionEvent{Event: s.c.newEvent(), StreamerId: s.id, Response: resp, Err: err}
+			s.c.notify(func(o Observer) { o.ConnectionUnusable(ev) })
+			// Signal is buffered, so there's no need to block.
 			select {
-			case s.ctl <- v:
+			case s.quit <- struct{}{}:
 			default:
 			}
 		}
 	}()
 }
 
-// Submits request to APN service and returns APN response or an error.
-func (s *streamer) submit(req *Request) (*Response, error) {
+// roundTripContext returns the context to be used for req's roundtrip.
+// It is done when either req's context or streamer's context is done.
+// The returned cancel function must be called once the roundtrip is over.
+func (s *streamer) roundTripContext(req *Request) (context.Context, context.CancelFunc) {
+	if req.Context == NoContext {
+		return s.ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(req.Context)
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// throttle blocks for as long as is required by the rate limiter, if any,
+// before httpReq can be sent. Waits are counted as outbound blocking.
+// ErrCanceled is returned if req's context is canceled while waiting,
+// and ErrPushInterrupted is returned on hard stop.
+func (s *streamer) throttle(req *Request, httpReq *http.Request) error {
+	lim := s.gov.config().limiter
+	if lim == nil {
+		return nil
+	}
+	size := estimatedRequestWireSize(httpReq)
+	d := lim.reserve(size)
+	if d <= 0 {
+		return nil
+	}
+	var done <-chan struct{}
+	if req.Context != NoContext {
+		done = req.Context.Done()
+	}
+	s.waitCtr.Tick()
+	defer s.waitCtr.Tock()
+	tmr := time.NewTimer(d)
+	defer tmr.Stop()
+	select {
+	case <-tmr.C:
+		return nil
+	case <-done:
+		lim.cancel(size)
+		return ErrCanceled
+	case <-s.c.runCtx.Done():
+		lim.cancel(size)
+		return ErrPushInterrupted
+	}
+}
+
+// newHTTPRequest creates and signs an HTTP request for req.
+func (s *streamer) newHTTPRequest(req *Request) (*http.Request, error) {
 	url := s.c.Gateway + RequestRoot + req.Notification.Recipient
 	httpReq, err := http.NewRequest("POST", url, nil)
 	if err != nil {
 		return nil, &RequestError{err}
 	}
 	if err := req.Notification.write(httpReq); err != nil {
+		switch err.(type) {
+		case *HeaderError, *PayloadSizeError:
+			// Validation errors are request-level errors in their own right.
+			return nil, err
+		}
 		return nil, &RequestError{err}
 	}
 	signer := req.Signer
@@ -207,76 +423,72 @@ func (s *streamer) submit(req *Request) (*Response, error) {
 			return nil, &RequestError{err}
 		}
 	}
-	if req.Context != NoContext {
-		httpReq = httpReq.WithContext(req.Context)
-	}
-	logTrace(2, s.id, "http.Request: %v\n", httpReq)
+	return httpReq, nil
+}
+
+// Submits request to APN service and returns APN response or an error.
+func (s *streamer) submit(httpReq *http.Request) (*Response, error) {
+	s.log.trace(2, "http.Request: %v", httpReq)
+	start := time.Now()
 	httpResp, err := s.httpClient.Do(httpReq)
 	if err != nil {
+		s.logOutcome(httpReq, nil, err, time.Since(start))
 		return nil, err
 	}
 	s.sizeCtr.Add(uint64(estimatedRequestWireSize(httpReq)))
-	logTrace(2, s.id, "http.Response: %v\n", httpResp)
+	s.log.trace(2, "http.Response: %v", httpResp)
 	defer httpResp.Body.Close()
 	res := &Response{
 		StatusCode: httpResp.StatusCode,
 		ApnsID:     httpResp.Header.Get("apns-id"),
 	}
 	decoder := json.NewDecoder(httpResp.Body)
-	if err := decoder.Decode(&res); err != nil && err != io.EOF {
-		return &Response{}, &RequestError{err}
+	err = decoder.Decode(&res)
+	latency := time.Since(start)
+	s.c.stats.addLatency(latency)
+	if err != nil && err != io.EOF {
+		err = &RequestError{err}
+		s.logOutcome(httpReq, res, err, latency)
+		return &Response{}, err
 	}
+	s.logOutcome(httpReq, res, nil, latency)
 	return res, nil
 }
 
-func (s *streamer) callBack(req *Request, resp *Response, err error) {
-	res := &Result{
-		Notification: req.Notification,
-		Signer:       req.Signer,
-		Context:      req.Context,
-		Response:     resp,
-		Err:          err,
-	}
-	if req.Callback == NoCallback {
+// logOutcome logs the outcome of a single push roundtrip.
+func (s *streamer) logOutcome(httpReq *http.Request, resp *Response, err error, latency time.Duration) {
+	sev := LogTrace(0)
+	if !s.log.enabled(sev) {
 		return
 	}
-	tgt := s.out
-	if req.Callback != nil {
-		tgt = req.Callback
+	fields := []Field{
+		{FieldApnsID, httpReq.Header.Get("apns-id")},
+		{FieldTopic, httpReq.Header.Get("apns-topic")},
 	}
-	if tgt != nil && tgt != NoCallback {
-		isBlocked := false
-		select {
-		case tgt <- res:
-		default:
-			isBlocked = true
-		}
-		if !isBlocked {
-			return
+	if resp != nil {
+		if resp.ApnsID != "" {
+			fields[0].Value = resp.ApnsID
 		}
-		s.waitCtr.Tick()
-		select {
-		case tgt <- res:
-		case <-s.ctl:
+		fields = append(fields, Field{FieldStatus, resp.StatusCode})
+		if resp.RejectionReason != "" {
+			fields = append(fields, Field{FieldReason, resp.RejectionReason})
 		}
-		s.waitCtr.Tock()
 	}
+	fields = append(fields, Field{FieldLatency, latency})
+	if err != nil {
+		fields = append(fields, Field{FieldError, err})
+	}
+	s.log.log(sev, "Push completed.", fields...)
 }
 
-func (s *streamer) isRetriable(resp *Response, err error) bool {
-	if resp == nil && err != nil {
-		return false
-	}
-	if s.gov.cfg.RetryEval != nil {
-		return s.gov.cfg.RetryEval(resp, err)
-	}
-	return false
+func (s *streamer) callBack(req *Request, resp *Response, err error) {
+	s.c.callBack(req, resp, err, &s.waitCtr, s.ctl)
 }
 
 func (s *streamer) isConnUsable(resp *Response, err error) bool {
 	if resp == nil && err != nil {
 		switch err.(type) {
-		case *RequestError:
+		case *RequestError, *HeaderError, *PayloadSizeError:
 			// Request-level error
 			return true
 		default:
@@ -303,6 +515,35 @@ func (s *streamer) isConnUsable(resp *Response, err error) bool {
 	return true
 }
 
+// goAwayError returns the GOAWAY details if err is the result of the server
+// closing the connection after sending GOAWAY, or nil otherwise.
+func goAwayError(err error) *http2.GoAwayError {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	if ga, ok := err.(http2.GoAwayError); ok {
+		return &ga
+	}
+	return nil
+}
+
+// byUtilization sorts streamers in the order of increasing number
+// of reserved HTTP/2 streams.
+type byUtilization []*streamer
+
+func (a byUtilization) Len() int           { return len(a) }
+func (a byUtilization) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byUtilization) Less(i, j int) bool { return a[i].inFlight() < a[j].inFlight() }
+
+// inFlight returns the number of HTTP/2 streams currently reserved
+// by the streamer.
+func (s *streamer) inFlight() uint32 {
+	if s.httpClient == nil {
+		return 0
+	}
+	return s.httpClient.inFlight()
+}
+
 var baseReqWireSizeSize = uint64(5 + len(RequestRoot))
 
 // Only an estimate and only based on the fields we use. I.e. cookie sizes
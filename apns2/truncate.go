@@ -0,0 +1,201 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AlertField designates a text field of payload's alert.
+type AlertField int
+
+const (
+	// AlertBody is alert's Body, or the alert itself if it is a string.
+	AlertBody AlertField = iota
+	// AlertTitle is alert's Title.
+	AlertTitle
+	// AlertSubtitle is alert's Subtitle.
+	AlertSubtitle
+)
+
+// TruncateAlert shortens the designated text field of payload's alert,
+// if needed, so that JSON encoding of the whole payload takes at most
+// maxSize bytes, and appends ellipsis, such as "…", to the shortened text.
+// Trailing white space is removed from the shortened text before ellipsis
+// is appended. The text is only cut at grapheme cluster boundaries, so that
+// multi-byte characters, combining marks and emoji sequences are never split,
+// and any escaping the text requires in JSON is accounted for.
+// PushType's MaxPayloadSize is the usual choice for maxSize.
+// TruncateAlert returns true if the text was shortened.
+//
+// If the payload cannot be made to fit by shortening the field, for example
+// because its custom keys alone take up more than maxSize, a *PayloadSizeError
+// is returned and the payload is left unchanged.
+//
+// As payloads are not meant to be modified once in use, TruncateAlert
+// must not be called concurrently with the payload being sent.
+func (p *Payload) TruncateAlert(field AlertField, maxSize int, ellipsis string) (bool, error) {
+	buf, err := json.Marshal(p.mergedMap())
+	if err != nil {
+		return false, err
+	}
+	if len(buf) <= maxSize {
+		return false, nil
+	}
+	get, set := p.alertText(field)
+	text := get()
+	// JSON encoding of a string does not depend on its surroundings,
+	// so the size of everything else is what's left without the text.
+	avail := maxSize - (len(buf) - jsonTextLen(text)) - jsonTextLen(ellipsis)
+	if set == nil || avail < 0 {
+		return false, &PayloadSizeError{Size: len(buf), MaxSize: maxSize}
+	}
+	set(strings.TrimRightFunc(truncateText(text, avail), unicode.IsSpace) + ellipsis)
+	p.json = atomic.Value{}
+	if buf, err = json.Marshal(p.mergedMap()); err != nil || len(buf) > maxSize {
+		// The field is not part of the payload.
+		set(text)
+		if err == nil {
+			err = &PayloadSizeError{Size: len(buf), MaxSize: maxSize}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// alertText returns accessors of the designated text field of payload's
+// alert. Setter is nil if the field is not present.
+func (p *Payload) alertText(field AlertField) (func() string, func(string)) {
+	none := func() string { return "" }
+	if p.APS == nil {
+		return none, nil
+	}
+	var a *Alert
+	byValue := false
+	switch v := p.APS.Alert.(type) {
+	case string:
+		if field != AlertBody {
+			return none, nil
+		}
+		return func() string { return p.APS.Alert.(string) },
+			func(s string) { p.APS.Alert = s }
+	case *Alert:
+		a = v
+	case Alert:
+		a, byValue = &v, true
+	default:
+		return none, nil
+	}
+	var f *string
+	switch field {
+	case AlertBody:
+		f = &a.Body
+	case AlertTitle:
+		f = &a.Title
+	case AlertSubtitle:
+		f = &a.Subtitle
+	default:
+		return none, nil
+	}
+	if byValue {
+		// Alert is held by value, so it is replaced with a modified copy
+		// whenever the field is set, which also restores it on failure.
+		return func() string { return *f }, func(s string) { *f = s; p.APS.Alert = *a }
+	}
+	return func() string { return *f }, func(s string) { *f = s }
+}
+
+// truncateText returns the longest prefix of s that ends at a grapheme
+// cluster boundary and whose JSON encoding takes at most maxLen bytes,
+// not counting the quotes.
+func truncateText(s string, maxLen int) string {
+	best, n, ri := 0, 0, 0
+	prev := rune(-1)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		invalid := r == utf8.RuneError && size == 1
+		if i > 0 && (invalid || !joinsPrevious(prev, r, ri)) {
+			if n > maxLen {
+				return s[:best]
+			}
+			best = i
+		}
+		if isRegionalIndicator(r) {
+			ri++
+		} else {
+			ri = 0
+		}
+		n += jsonRuneLen(r, size)
+		prev = r
+		i += size
+	}
+	if n <= maxLen {
+		return s
+	}
+	return s[:best]
+}
+
+// joinsPrevious returns true if r continues the grapheme cluster of prev.
+// ri is the number of regional indicators immediately preceding r.
+// This covers the cases that matter for notification text: CR LF,
+// combining marks, variation selectors, emoji modifiers, tag sequences,
+// zero width joiner sequences and flag pairs.
+func joinsPrevious(prev, r rune, ri int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev < 0x20 || prev == 0x7f || r < 0x20 || r == 0x7f:
+		return false
+	case prev == 0x200d || r == 0x200d:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // emoji modifiers
+		return true
+	case r >= 0xe0020 && r <= 0xe007f: // tags
+		return true
+	case isRegionalIndicator(r):
+		return ri%2 == 1
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// jsonTextLen returns the length of JSON encoding of s, not counting
+// the quotes.
+func jsonTextLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		n += jsonRuneLen(r, size)
+		i += size
+	}
+	return n
+}
+
+// jsonRuneLen returns the length of JSON encoding of r, decoded from size
+// bytes, as produced by encoding/json.
+func jsonRuneLen(r rune, size int) int {
+	switch {
+	case r == '"' || r == '\\':
+		return 2
+	case r < 0x20:
+		// Short escapes vary with Go version.
+		buf, _ := json.Marshal(string(r))
+		return len(buf) - 2
+	case r == utf8.RuneError && size == 1:
+		// So does the encoding of invalid UTF-8.
+		buf, _ := json.Marshal("\xff")
+		return len(buf) - 2
+	case r == '<' || r == '>' || r == '&' || r == 0x2028 || r == 0x2029:
+		return 6
+	}
+	return size
+}
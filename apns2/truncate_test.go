@@ -0,0 +1,110 @@
+// Copyright 2017 Aleksey Blinov. All rights reserved.
+
+package apns2
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateText(t *testing.T) {
+	for _, c := range []struct {
+		s   string
+		max int
+		exp string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+		// Combining acute accent stays with its base letter.
+		{"héllo", 2, "h"},
+		{"héllo", 4, "hé"},
+		// Escaped characters take more room.
+		{`a"b`, 2, "a"},
+		{"a<b", 6, "a"},
+		{"a\nb", 3, "a\n"},
+		// Family emoji is a single grapheme cluster.
+		{"a👨‍👩‍👧b", 12, "a"},
+		{"a👨‍👩‍👧b", 19, "a👨‍👩‍👧"},
+		// Emoji with skin tone modifier.
+		{"👍🏽👍", 5, ""},
+		{"👍🏽👍", 8, "👍🏽"},
+		// Flags are pairs of regional indicators.
+		{"🇨🇦🇺🇸", 12, "🇨🇦"},
+		{"🇨🇦🇺🇸", 7, ""},
+		// Invalid bytes are never joined.
+		{"a\xff\u0301", 1, "a"},
+	} {
+		assert.Equal(t, c.exp, truncateText(c.s, c.max), "%q %d", c.s, c.max)
+	}
+}
+
+func TestJSONTextLen(t *testing.T) {
+	for _, s := range []string{"plain", "ünï", `q"b\s`, "<&>", "\x01\b\f\n\r\t", " ", "a\xffb", "�", "👨‍👩"} {
+		buf, _ := json.Marshal(s)
+		assert.Equal(t, len(buf)-2, jsonTextLen(s), "%q", s)
+	}
+}
+
+func TestPayload_TruncateAlert(t *testing.T) {
+	body := strings.Repeat("Lorem ipsum dolor sit amet, é👍🏽 ", 300)
+	p := &Payload{
+		APS: &APS{Alert: &Alert{Title: "Message", Body: body}, Sound: "default"},
+		Raw: map[string]interface{}{"thread": strings.Repeat("x", 100)},
+	}
+	ok, err := p.TruncateAlert(AlertBody, MaxPayloadSize, "…")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	j, err := p.MarshalJSON()
+	assert.Nil(t, err)
+	assert.True(t, len(j) <= MaxPayloadSize)
+	assert.True(t, len(j) > MaxPayloadSize-20)
+	b := p.APS.Alert.(*Alert).Body
+	assert.True(t, utf8.ValidString(b))
+	assert.True(t, strings.HasSuffix(b, "…"))
+	assert.True(t, strings.HasPrefix(body, strings.TrimSuffix(b, "…")))
+	assert.Equal(t, "Message", p.APS.Alert.(*Alert).Title)
+	// Payload that already fits is left alone.
+	ok, err = p.TruncateAlert(AlertBody, MaxPayloadSize, "…")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestPayload_TruncateAlert_String(t *testing.T) {
+	p := &Payload{APS: &APS{Alert: strings.Repeat("<b>", 100)}}
+	ok, err := p.TruncateAlert(AlertBody, 100, "...")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	j, _ := p.MarshalJSON()
+	assert.True(t, len(j) <= 100)
+	assert.True(t, strings.HasSuffix(p.APS.Alert.(string), "..."))
+}
+
+func TestPayload_TruncateAlert_Impossible(t *testing.T) {
+	p := &Payload{
+		APS: &APS{Alert: &Alert{Body: "Hello"}},
+		Raw: map[string]interface{}{"data": strings.Repeat("x", 200)},
+	}
+	ok, err := p.TruncateAlert(AlertBody, 100, "…")
+	assert.False(t, ok)
+	assert.IsType(t, &PayloadSizeError{}, err)
+	assert.Equal(t, "Hello", p.APS.Alert.(*Alert).Body)
+	// Alert held by value is left as is.
+	p = &Payload{
+		APS: &APS{Alert: Alert{Body: "Hello"}},
+		Raw: map[string]interface{}{"data": strings.Repeat("x", 200)},
+	}
+	ok, err = p.TruncateAlert(AlertBody, 100, "…")
+	assert.False(t, ok)
+	assert.IsType(t, &PayloadSizeError{}, err)
+	assert.Equal(t, Alert{Body: "Hello"}, p.APS.Alert)
+	// Field that is not in the payload cannot help.
+	p = &Payload{APS: &APS{Alert: strings.Repeat("x", 200)}}
+	_, err = p.TruncateAlert(AlertTitle, 100, "…")
+	assert.IsType(t, &PayloadSizeError{}, err)
+}
@@ -51,6 +51,34 @@ func GetMaxConcurrentStreams(c *http2.ClientConn) uint32 {
 	return *res
 }
 
+// GetGoAway returns the details of the GOAWAY frame received by c,
+// if any, using reflection. It properly guards its read with c's mutex.
+//
+// Nil is returned if c is nil, if c hasn't received a GOAWAY frame or if
+// the frame cannot be retrieved due to http2.ClientConn incompatibility.
+// Frame's debug data is only included if c retains it.
+func GetGoAway(c *http2.ClientConn) *http2.GoAwayError {
+	if c == nil || !http2Compat {
+		return nil
+	}
+	rc := reflect.Indirect(reflect.ValueOf(c))
+	mu := (*sync.Mutex)(ptrToFieldValue(rc, clientConn.mu))
+	mu.Lock()
+	defer mu.Unlock()
+	f := *(**http2.GoAwayFrame)(ptrToFieldValue(rc, clientConn.goAway))
+	if f == nil {
+		return nil
+	}
+	res := &http2.GoAwayError{
+		LastStreamID: f.LastStreamID,
+		ErrCode:      f.ErrCode,
+	}
+	if clientConn.goAwayDebug != nil {
+		res.DebugData = *(*string)(ptrToFieldValue(rc, clientConn.goAwayDebug))
+	}
+	return res
+}
+
 var dummyReq http.Request
 
 // GetClientConnPool returns http2.Transport t's ClientConnPool. If t is not a
@@ -97,6 +125,7 @@ var clientConn struct {
 	maxConcurrentStreams []int
 	closed               []int
 	goAway               []int
+	goAwayDebug          []int // optional
 }
 
 var transport struct {
@@ -142,6 +171,10 @@ func init() {
 	} else {
 		http2Compat = false
 	}
+	// Not all versions retain GOAWAY debug data, so it's not required.
+	if f, ok := c.FieldByName("goAwayDebug"); ok && f.Type.Kind() == reflect.String {
+		clientConn.goAwayDebug = f.Index
+	}
 	// Validate http2.Transport structure
 	t := reflect.TypeOf(&http2.Transport{}).Elem()
 	if f, ok := t.FieldByName("connPoolOrDef"); ok {
@@ -33,3 +33,9 @@ func TestGetClientConnPool(t *testing.T) {
 		t.Fatal("Should have gotten connection pool")
 	}
 }
+
+func TestGetGoAway(t *testing.T) {
+	if res := GetGoAway(nil); res != nil {
+		t.Fatal("Should not have gotten GOAWAY details")
+	}
+}